@@ -0,0 +1,130 @@
+package cast
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// annotateCastedFile records the annotations protoc-gen-go and the grpc
+// plugin make on the symbols of a generated file, which are lost when the
+// casted file replaces it. protogen computes their offsets from the final
+// content, so they account for every cast and tag change. The methods the
+// cast plugin adds are annotated with the location of their field.
+//
+// Only the symbols the file declares are annotated, as protogen rejects
+// annotations it cannot find.
+func annotateCastedFile(g *protogen.GeneratedFile, file *protogen.File, symbols map[string]bool) {
+	annotate := func(symbol string, loc protogen.Location) {
+		if symbols[symbol] {
+			g.Annotate(symbol, loc)
+		}
+	}
+	for _, enum := range file.Enums {
+		annotateEnum(annotate, enum)
+	}
+	annotateMessages(annotate, file.Messages)
+	for _, service := range file.Services {
+		for _, name := range []string{service.GoName + "Client", service.GoName + "Server"} {
+			annotate(name, service.Location)
+			for _, method := range service.Methods {
+				annotate(name+"."+method.GoName, method.Location)
+			}
+		}
+	}
+}
+
+func annotateEnum(annotate func(string, protogen.Location), enum *protogen.Enum) {
+	annotate(enum.GoIdent.GoName, enum.Location)
+	for _, value := range enum.Values {
+		annotate(value.GoIdent.GoName, value.Location)
+	}
+}
+
+func annotateMessages(annotate func(string, protogen.Location), messages []*protogen.Message) {
+	for _, message := range messages {
+		for _, enum := range message.Enums {
+			annotateEnum(annotate, enum)
+		}
+		annotateMessages(annotate, message.Messages)
+		if message.Desc.IsMapEntry() {
+			continue
+		}
+		name := message.GoIdent.GoName
+		annotate(name, message.Location)
+		for _, oneof := range message.Oneofs {
+			if oneof.Desc.IsSynthetic() {
+				continue
+			}
+			annotate(name+"."+oneof.GoName, oneof.Location)
+			annotate(name+".Get"+oneof.GoName, oneof.Location)
+			for _, field := range oneof.Fields {
+				annotate(field.GoIdent.GoName, field.Location)
+				annotate(field.GoIdent.GoName+"."+field.GoName, field.Location)
+			}
+		}
+		for _, field := range message.Fields {
+			if field.Oneof == nil || field.Oneof.Desc.IsSynthetic() {
+				annotate(name+"."+field.GoName, field.Location)
+			}
+			for _, method := range []string{"Get", "Has", "Clear", "Set"} {
+				annotate(name+"."+method+field.GoName, field.Location)
+			}
+		}
+	}
+}
+
+// declaredSymbols returns the symbols declared in a Go file, named the way
+// protogen.GeneratedFile.Annotate expects.
+func declaredSymbols(content []byte) (map[string]bool, error) {
+	astFile, err := parser.ParseFile(token.NewFileSet(), "", content, 0)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]bool)
+	for _, decl := range astFile.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					symbols[spec.Name.Name] = true
+					var fields *ast.FieldList
+					switch typ := spec.Type.(type) {
+					case *ast.StructType:
+						fields = typ.Fields
+					case *ast.InterfaceType:
+						fields = typ.Methods
+					}
+					if fields == nil {
+						continue
+					}
+					for _, field := range fields.List {
+						for _, name := range field.Names {
+							symbols[spec.Name.Name+"."+name.Name] = true
+						}
+					}
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						symbols[name.Name] = true
+					}
+				}
+			}
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				symbols[decl.Name.Name] = true
+				continue
+			}
+			recv := decl.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				symbols[id.Name+"."+decl.Name.Name] = true
+			}
+		}
+	}
+	return symbols, nil
+}
@@ -0,0 +1,634 @@
+// Package cast rewrites the .pb.go files protoc-gen-go generates so that the
+// fields annotated with the options of cast/options.proto have custom Go
+// types, and so that other custom options become struct tags.
+//
+// A plugin generates the .pb.go files, plans their rewrites with a Caster
+// and then applies the plans:
+//
+//	c, err := cast.NewCaster(gen, cast.Options{})
+//	...
+//	plan, err := c.BuildPlan(file, gengo.GenerateFile(gen, file))
+//	...
+//	err = c.Apply(plans...)
+package cast
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Options configures how files are cast.
+type Options struct {
+	// Setters generates a SetX method next to the getter of every cast field.
+	// Messages opt in on their own with the cast_setters option.
+	Setters bool
+	// LegacyOptionNames also treats extensions declared outside of
+	// cast/options.proto as cast options when their short name matches, as
+	// in protos that declare their own cast_type. Without it, setting such
+	// an extension is an error.
+	LegacyOptionNames bool
+	// ImportRewriteFunc rewrites the import paths of cast types imported
+	// under an explicit alias. Other imports are rewritten by the
+	// protogen.Options the plugin runs with, which should use the same
+	// function.
+	ImportRewriteFunc func(protogen.GoImportPath) protogen.GoImportPath
+	// Jobs is the number of files Caster.Apply rewrites at a time. Zero
+	// means one.
+	Jobs int
+}
+
+// Apply replaces gennedFile, the .pb.go file protoc-gen-go generated for
+// file, with its cast typed contents. Errors caused by an annotation point at
+// the field in the .proto source.
+//
+// Apply reads the options declared anywhere in the request. A plugin that
+// casts several files should use a Caster, which reads them once.
+func Apply(gen *protogen.Plugin, file *protogen.File, gennedFile *protogen.GeneratedFile, opts Options) error {
+	c, err := NewCaster(gen, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file.Desc.Path(), err)
+	}
+	plan, err := c.BuildPlan(file, gennedFile)
+	if err != nil {
+		return err
+	}
+	return c.Apply(plan)
+}
+
+// Caster casts the files of one run of a plugin. It indexes the custom
+// options declared in the request once, and decodes the options of each field
+// and message once.
+type Caster struct {
+	gen   *protogen.Plugin
+	opts  Options
+	types *optionTypes
+}
+
+// NewCaster returns a Caster for the files of gen.
+func NewCaster(gen *protogen.Plugin, opts Options) (*Caster, error) {
+	var allExtensions []*protogen.Extension
+	for _, f := range gen.Files {
+		allExtensions = append(allExtensions, f.Extensions...)
+	}
+	types, err := extensionTypes(allExtensions, opts.LegacyOptionNames)
+	if err != nil {
+		return nil, err
+	}
+	return &Caster{gen: gen, opts: opts, types: types}, nil
+}
+
+// BuildPlan plans the rewrite of gennedFile, the .pb.go file protoc-gen-go
+// generated for file. Until the plan is applied, other generators may add
+// code to gennedFile, which is kept in the casted file.
+//
+// BuildPlan adds no files to the plugin, but it is not safe to call
+// concurrently.
+func (c *Caster) BuildPlan(file *protogen.File, gennedFile *protogen.GeneratedFile) (*Plan, error) {
+	return buildPlan(gennedFile, file, c.types, c.opts)
+}
+
+// castedFile holds the casted contents of a .pb.go file and the symbols
+// they declare.
+type castedFile struct {
+	content []byte
+	symbols map[string]bool
+}
+
+// renderCastedFile computes the casted contents of the .pb.go file of plan.
+// It only reads gen and the files protogen made for plan, so different files
+// may be rendered concurrently.
+func renderCastedFile(gen *protogen.Plugin, file *protogen.File, plan *Plan, opts Options) (*castedFile, error) {
+	gennedFile := plan.gennedFile
+	preFunc := func(c *astutil.Cursor) bool {
+		return true
+	}
+
+	postFunc := func(c *astutil.Cursor) bool {
+		n := c.Node()
+		structType, structOk := n.(*ast.StructType)
+		if structOk {
+			replacementFields := structType.Fields
+			decl, ok := c.Parent().(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			for i, field := range replacementFields.List {
+				if field.Tag == nil || len(field.Names) == 0 {
+					continue
+				}
+
+				rewrite, ok := plan.fields[fieldKey{Struct: decl.Name.Name, Field: field.Names[0].Name}]
+				if !ok {
+					continue
+				}
+				if rewrite.CastType != nil {
+					replacementFields.List[i].Type = rewrite.structType()
+				}
+				if rewrite.MapType != nil {
+					rewrite.MapType.apply(replacementFields.List[i].Type)
+				}
+				if rewrite.Tags != "" {
+					replacementFields.List[i].Tag = &ast.BasicLit{
+						Kind:     token.STRING,
+						ValuePos: field.Tag.ValuePos,
+						Value:    fmt.Sprintf("%s%s`", field.Tag.Value[:len(field.Tag.Value)-1], rewrite.Tags),
+					}
+				}
+			}
+			replacement := &ast.StructType{
+				Struct:     structType.Struct,
+				Fields:     replacementFields,
+				Incomplete: structType.Incomplete,
+			}
+			c.Replace(replacement)
+		}
+
+		funcDecl, funcOk := n.(*ast.FuncDecl)
+		if funcOk {
+			key, ok := methodKeyOf(funcDecl)
+			if !ok {
+				return true
+			}
+			rewrite, ok := plan.getters[key]
+			if !ok {
+				return true
+			}
+			// Getters take no parameters and return the field.
+			if len(funcDecl.Type.Params.List) > 0 || funcDecl.Type.Results == nil || len(funcDecl.Type.Results.List) != 1 {
+				return true
+			}
+			if rewrite.MapType != nil {
+				// Map getters return nil as their zero value, which needs no cast.
+				rewrite.MapType.apply(funcDecl.Type.Results.List[0].Type)
+				return true
+			}
+
+			replacement := &ast.FuncDecl{
+				Doc:  funcDecl.Doc,
+				Recv: funcDecl.Recv,
+				Name: funcDecl.Name,
+				Type: funcDecl.Type,
+				Body: funcDecl.Body,
+			}
+			body := replacement.Body.List
+			if len(body) > 0 {
+				lastStmt := body[len(body)-1]
+				returnStmt, ok := lastStmt.(*ast.ReturnStmt)
+				if !ok {
+					return true
+				}
+				returnStmt.Results[0] = rewrite.castDefault(returnStmt.Results[0])
+				replacement.Body.List[len(body)-1] = returnStmt
+			}
+			replacement.Type.Results.List[0].Type = rewrite.getterType()
+			c.Replace(replacement)
+			return true
+		}
+		return true
+	}
+
+	content, err := gennedFile.Content()
+	if err != nil {
+		return nil, fmt.Errorf("%s: generating Go code: %v", file.Desc.Path(), err)
+	}
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("%s: parsing generated Go code: %v", file.Desc.Path(), err)
+	}
+	var versions []toolVersion
+	if header := generatedHeader(astFile); header != nil {
+		versions = headerVersions(header)
+	}
+	if err := dropReservedImports(fset, astFile, plan.reserved); err != nil {
+		return nil, fmt.Errorf("%s: dropping reserved imports: %v", file.Desc.Path(), err)
+	}
+	if plan.loaded {
+		if err := mergeImports(fset, astFile); err != nil {
+			return nil, fmt.Errorf("%s: merging imports: %v", file.Desc.Path(), err)
+		}
+	}
+
+	if err := addAliasedImports(fset, astFile, plan.imports, opts.ImportRewriteFunc); err != nil {
+		return nil, err
+	}
+
+	result := astutil.Apply(astFile, preFunc, postFunc)
+	resultFile := result.(*ast.File)
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, resultFile); err != nil {
+		return nil, fmt.Errorf("%s: printing casted Go code: %v", file.Desc.Path(), err)
+	}
+	casted, err := insertAfterMethods(buf.Bytes(), plan.insertions)
+	if err != nil {
+		return nil, fmt.Errorf("%s: adding methods to casted Go code: %v", file.Desc.Path(), err)
+	}
+	casted, err = format.Source(casted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: formatting casted Go code: %v", file.Desc.Path(), err)
+	}
+	casted, err = replaceHeader(casted, castHeader(file, plan, versions))
+	if err != nil {
+		return nil, fmt.Errorf("%s: writing header of casted Go code: %v", file.Desc.Path(), err)
+	}
+	symbols, err := declaredSymbols(casted)
+	if err != nil {
+		return nil, fmt.Errorf("%s: annotating casted Go code: %v", file.Desc.Path(), err)
+	}
+	return &castedFile{content: casted, symbols: symbols}, nil
+}
+
+// replace skips the .pb.go file gengo generated for plan and adds the casted
+// file in its place. It adds a file to gen, so calls must not overlap.
+func (c *castedFile) replace(gen *protogen.Plugin, file *protogen.File, plan *Plan) error {
+	plan.gennedFile.Skip()
+	filename := file.GeneratedFilenamePrefix + ".pb.go"
+	newGennedFile := gen.NewGeneratedFile(filename, file.GoImportPath)
+	if _, err := newGennedFile.Write(c.content); err != nil {
+		return fmt.Errorf("%s: writing casted Go code: %v", file.Desc.Path(), err)
+	}
+	annotateCastedFile(newGennedFile, file, c.symbols)
+	return nil
+}
+
+// addAliasedImports adds the imports of cast types with an explicit alias to f,
+// rewriting their paths with rewrite if set. An alias that already names
+// another package in f is an error.
+func addAliasedImports(fset *token.FileSet, f *ast.File, imports []aliasedImport, rewrite func(protogen.GoImportPath) protogen.GoImportPath) error {
+	used := make(map[string]string)
+	for _, spec := range f.Imports {
+		if spec.Name != nil {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return err
+			}
+			used[spec.Name.Name] = importPath
+		}
+	}
+	for _, imp := range imports {
+		path := imp.Path
+		if rewrite != nil {
+			path = string(rewrite(protogen.GoImportPath(path)))
+		}
+		if importPath, ok := used[imp.Name]; ok {
+			if importPath != path {
+				return fieldError(imp.Field, fmt.Errorf("import alias %s of %q is already used for %q", imp.Name, path, importPath))
+			}
+			continue
+		}
+		astutil.AddNamedImport(fset, f, imp.Name, path)
+		used[imp.Name] = path
+	}
+	return nil
+}
+
+// methodKeyOf returns the key of a method declared on a pointer receiver.
+func methodKeyOf(funcDecl *ast.FuncDecl) (methodKey, bool) {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 {
+		return methodKey{}, false
+	}
+	star, ok := funcDecl.Recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return methodKey{}, false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	if !ok {
+		return methodKey{}, false
+	}
+	return methodKey{Receiver: ident.Name, Method: funcDecl.Name.Name}, true
+}
+
+// isPointerField reports whether gengo generates a pointer for a field, which
+// it does for scalars with explicit presence outside of a real oneof such as
+// proto3 optional fields.
+func isPointerField(field *protogen.Field) bool {
+	if field.Desc.IsList() || field.Desc.IsMap() || !field.Desc.HasPresence() {
+		return false
+	}
+	switch field.Desc.Kind() {
+	case protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return field.Oneof == nil || field.Oneof.Desc.IsSynthetic()
+}
+
+// hasFieldNamed reports whether message has a field generated with any of
+// names, which would collide with a method of the same name.
+func hasFieldNamed(message *protogen.Message, names ...string) bool {
+	for _, field := range message.Fields {
+		for _, name := range names {
+			if field.GoName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// presenceMethods returns HasX and ClearX methods for a cast pointer field.
+func presenceMethods(receiver string, field *protogen.Field) string {
+	return fmt.Sprintf(`
+
+// Has%[2]s reports whether the %[3]s field is set.
+func (x *%[1]s) Has%[2]s() bool {
+	return x != nil && x.%[2]s != nil
+}
+
+// Clear%[2]s clears the %[3]s field.
+func (x *%[1]s) Clear%[2]s() {
+	if x != nil {
+		x.%[2]s = nil
+	}
+}`, receiver, field.GoName, field.Desc.Name())
+}
+
+// castSetterType returns the Go type a setter of field takes, or an empty
+// string if the field is not cast.
+func castSetterType(g *protogen.GeneratedFile, field *protogen.Field, rewrite *fieldRewrite) string {
+	if rewrite.MapType != nil {
+		keyType := elementGoType(g, field.Message.Fields[0])
+		if rewrite.MapType.key != nil {
+			keyType = rewrite.MapType.key.String()
+		}
+		valueType := elementGoType(g, field.Message.Fields[1])
+		if rewrite.MapType.value != nil {
+			valueType = rewrite.MapType.value.String()
+		}
+		return fmt.Sprintf("map[%s]%s", keyType, valueType)
+	}
+	if rewrite.CastType == nil {
+		return ""
+	}
+	return types.ExprString(rewrite.getterType())
+}
+
+// elementGoType returns the Go type gengo generates for a single value of
+// field, such as a map key or value.
+func elementGoType(g *protogen.GeneratedFile, field *protogen.Field) string {
+	switch field.Desc.Kind() {
+	case protoreflect.EnumKind:
+		return g.QualifiedGoIdent(field.Enum.GoIdent)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "*" + g.QualifiedGoIdent(field.Message.GoIdent)
+	}
+	return goScalarTypes[field.Desc.Kind()]
+}
+
+var goScalarTypes = map[protoreflect.Kind]string{
+	protoreflect.BoolKind:     "bool",
+	protoreflect.Int32Kind:    "int32",
+	protoreflect.Sint32Kind:   "int32",
+	protoreflect.Sfixed32Kind: "int32",
+	protoreflect.Int64Kind:    "int64",
+	protoreflect.Sint64Kind:   "int64",
+	protoreflect.Sfixed64Kind: "int64",
+	protoreflect.Uint32Kind:   "uint32",
+	protoreflect.Fixed32Kind:  "uint32",
+	protoreflect.Uint64Kind:   "uint64",
+	protoreflect.Fixed64Kind:  "uint64",
+	protoreflect.FloatKind:    "float32",
+	protoreflect.DoubleKind:   "float64",
+	protoreflect.StringKind:   "string",
+	protoreflect.BytesKind:    "[]byte",
+}
+
+// setterMethod returns a nil safe SetX method for a cast field. Oneof members
+// are set through their wrapper type and optional fields through a pointer.
+func setterMethod(receiver string, field *protogen.Field, setType string) string {
+	assign := fmt.Sprintf("x.%s = v", field.GoName)
+	switch {
+	case field.Oneof != nil && !field.Oneof.Desc.IsSynthetic():
+		assign = fmt.Sprintf("x.%s = &%s{%s: v}", field.Oneof.GoName, field.GoIdent.GoName, field.GoName)
+	case isPointerField(field):
+		assign = fmt.Sprintf("x.%s = &v", field.GoName)
+	}
+	return fmt.Sprintf(`
+
+// Set%[2]s sets the %[3]s field.
+func (x *%[1]s) Set%[2]s(v %[4]s) {
+	if x != nil {
+		%[5]s
+	}
+}`, receiver, field.GoName, field.Desc.Name(), setType, assign)
+}
+
+// insertAfterMethods splices source code in after the declarations of methods.
+func insertAfterMethods(src []byte, insertions map[methodKey]string) ([]byte, error) {
+	if len(insertions) == 0 {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	type splice struct {
+		offset int
+		code   string
+	}
+	var splices []splice
+	for _, decl := range astFile.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		key, ok := methodKeyOf(funcDecl)
+		if !ok {
+			continue
+		}
+		if code, ok := insertions[key]; ok {
+			splices = append(splices, splice{offset: fset.Position(funcDecl.End()).Offset, code: code})
+		}
+	}
+	var out bytes.Buffer
+	last := 0
+	for _, s := range splices {
+		out.Write(src[last:s.offset])
+		out.WriteString(s.code)
+		last = s.offset
+	}
+	out.Write(src[last:])
+	return out.Bytes(), nil
+}
+
+// fieldError prefixes err with the .proto file, line and column of field.
+func fieldError(field *protogen.Field, err error) error {
+	return fmt.Errorf("%s: field %s: %v", fieldPosition(field), field.Desc.FullName(), err)
+}
+
+// fieldPosition returns the .proto file, line and column of field, or only
+// the file if the request has no source info for it.
+func fieldPosition(field *protogen.Field) string {
+	loc := field.Desc.ParentFile().SourceLocations().ByPath(protoreflect.SourcePath(field.Location.Path))
+	if loc.Path == nil {
+		return field.Location.SourceFile
+	}
+	return fmt.Sprintf("%s:%d:%d", field.Location.SourceFile, loc.StartLine+1, loc.StartColumn+1)
+}
+
+// validateCastType checks that a cast type names a Go type, optionally
+// qualified by its import path and the alias to import it as.
+func validateCastType(castType string) error {
+	if castType == "" {
+		return nil
+	}
+	ref := parseCastType(castType)
+	if strings.ContainsAny(ref.ImportPath, " \t\"`;") {
+		return fmt.Errorf("malformed import path %q", ref.ImportPath)
+	}
+	if strings.Contains(castType, ";") {
+		if ref.ImportPath == "" {
+			return fmt.Errorf("import alias %q without an import path", ref.Alias)
+		}
+		if !token.IsIdentifier(ref.Alias) || ref.Alias == "_" {
+			return fmt.Errorf("invalid import alias %q", ref.Alias)
+		}
+	}
+	if !token.IsIdentifier(ref.Name) {
+		return fmt.Errorf("%q does not end in a Go type name", castType)
+	}
+	return nil
+}
+
+// mapCastType holds the cast key and value types of a map field. Either may be
+// nil to keep the generated type.
+type mapCastType struct {
+	key   *typeName
+	value *typeName
+}
+
+// apply replaces the key and value of a generated map type expression.
+func (m mapCastType) apply(expr ast.Expr) {
+	mapType, ok := expr.(*ast.MapType)
+	if !ok {
+		return
+	}
+	if m.key != nil {
+		mapType.Key = m.key.expr()
+	}
+	if m.value != nil {
+		mapType.Value = m.value.expr()
+	}
+}
+
+// castTypeFromField returns the value of the cast_type option set on a field.
+func castTypeFromField(options []customOption) string {
+	return stringFieldOption(options, "cast_type")
+}
+
+// stringFieldOption returns the value of the string cast option with the
+// given name, or an empty string if it is not set.
+func stringFieldOption(options []customOption, name protoreflect.Name) string {
+	for _, opt := range options {
+		if opt.Cast == name && opt.Desc.Kind() == protoreflect.StringKind {
+			return opt.Value.String()
+		}
+	}
+	return ""
+}
+
+// boolOption reports whether the bool cast option with the given name is set
+// to true.
+func boolOption(options []customOption, name protoreflect.Name) bool {
+	for _, opt := range options {
+		if opt.Cast == name && opt.Desc.Kind() == protoreflect.BoolKind {
+			return opt.Value.Bool()
+		}
+	}
+	return false
+}
+
+// structTagsFromField formats every scalar option set on a field as a struct
+// tag. The options of cast/options.proto only configure the plugin and are
+// left out.
+func structTagsFromField(options []customOption) string {
+	var tags []string
+	for _, opt := range options {
+		if opt.Tag == "" {
+			continue
+		}
+		value, ok := optionString(opt)
+		if !ok {
+			continue
+		}
+		tags = append(tags, fmt.Sprintf(" %s:%s", opt.Tag, strconv.Quote(value)))
+	}
+	sort.Strings(tags)
+	allTags := strings.Join(tags, "")
+	return allTags
+}
+
+// castTypeRef is a cast type as written in a .proto file, either
+// "import/path.Name" or "import/path;alias.Name". Predeclared types and types
+// of the generated package itself have no import path.
+type castTypeRef struct {
+	ImportPath string
+	Alias      string
+	Name       string
+}
+
+// parseCastType splits a cast type into its import path, explicit alias and
+// type name.
+func parseCastType(castType string) castTypeRef {
+	typeStartIdx := strings.LastIndex(castType, ".")
+	if typeStartIdx == -1 {
+		return castTypeRef{Name: castType}
+	}
+	ref := castTypeRef{ImportPath: castType[:typeStartIdx], Name: castType[typeStartIdx+1:]}
+	if i := strings.LastIndex(ref.ImportPath, ";"); i >= 0 {
+		ref.ImportPath, ref.Alias = ref.ImportPath[:i], ref.ImportPath[i+1:]
+	}
+	return ref
+}
+
+// typeName is a named Go type as it is referred to in the generated file,
+// qualified by the name its package is imported as unless it is local or
+// predeclared.
+type typeName struct {
+	Package string
+	Name    string
+	// ImportPath is the import path of Package as the cast type names it.
+	ImportPath string
+}
+
+func (t typeName) String() string {
+	if t.Package == "" {
+		return t.Name
+	}
+	return t.Package + "." + t.Name
+}
+
+// qualified returns t qualified by its import path rather than its package
+// name, as reports refer to it.
+func (t typeName) qualified() string {
+	if t.ImportPath == "" {
+		return t.Name
+	}
+	return t.ImportPath + "." + t.Name
+}
+
+// expr returns a new type expression for t.
+func (t typeName) expr() ast.Expr {
+	if t.Package == "" {
+		return ast.NewIdent(t.Name)
+	}
+	return &ast.SelectorExpr{X: ast.NewIdent(t.Package), Sel: ast.NewIdent(t.Name)}
+}
+
+func snakeToCamel(text string) string {
+	newText := strings.ReplaceAll(text, "_", "-")
+	return newText
+}
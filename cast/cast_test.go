@@ -0,0 +1,1203 @@
+package cast
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/internal/casttest"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func Test_parseCastType(t *testing.T) {
+	tests := []struct {
+		name     string
+		castType string
+		want     castTypeRef
+	}{
+		{
+			name:     "native type",
+			castType: "string",
+			want:     castTypeRef{Name: "string"},
+		},
+		{
+			name:     "url",
+			castType: "github.com/prysmaticlabs/go-bitfield.Bitfield",
+			want:     castTypeRef{ImportPath: "github.com/prysmaticlabs/go-bitfield", Name: "Bitfield"},
+		},
+		{
+			name:     "alias",
+			castType: "github.com/prysmaticlabs/go-bitfield;bf.Bitlist",
+			want:     castTypeRef{ImportPath: "github.com/prysmaticlabs/go-bitfield", Alias: "bf", Name: "Bitlist"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCastType(tt.castType); got != tt.want {
+				t.Errorf("parseCastType() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_importPathName(t *testing.T) {
+	tests := []struct {
+		importPath string
+		want       string
+	}{
+		{importPath: "github.com/a/primitives", want: "primitives"},
+		{importPath: "github.com/prysmaticlabs/go-bitfield", want: "bitfield"},
+		{importPath: "github.com/a/bitfield-go", want: "bitfield"},
+		{importPath: "github.com/a/primitives/v2", want: "primitives"},
+		{importPath: "gopkg.in/yaml.v2", want: "yaml"},
+		{importPath: "v2", want: "v2"},
+		{importPath: "github.com/a/-", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.importPath, func(t *testing.T) {
+			if got := importPathName(tt.importPath); got != tt.want {
+				t.Errorf("importPathName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateCastType(t *testing.T) {
+	tests := []struct {
+		castType string
+		wantErr  string
+	}{
+		{castType: "github.com/prysmaticlabs/go-bitfield.Bitlist"},
+		{castType: "github.com/prysmaticlabs/go-bitfield;bitfield.Bitlist"},
+		{castType: "github.com/prysmaticlabs/go-bitfield;.Bitlist", wantErr: `invalid import alias ""`},
+		{castType: "github.com/prysmaticlabs/go-bitfield;_.Bitlist", wantErr: `invalid import alias "_"`},
+		{castType: ";bf.Bitlist", wantErr: `import alias "bf" without an import path`},
+		{castType: "github.com/prysmaticlabs/go-bitfield.", wantErr: `"github.com/prysmaticlabs/go-bitfield." does not end in a Go type name`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.castType, func(t *testing.T) {
+			err := validateCastType(tt.castType)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("validateCastType() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_castTypeFromField(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(
+		casttest.Message("Attestation",
+			casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield.Bitlist"),
+			casttest.Field("signature", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+		),
+	))
+	types, err := extensionTypes(testExtensions(gen), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		field *protogen.Field
+		want  string
+	}{
+		{
+			name:  "cast",
+			field: gen.Files[len(gen.Files)-1].Messages[0].Fields[0],
+			want:  "github.com/prysmaticlabs/go-bitfield.Bitlist",
+		},
+		{
+			name:  "no options",
+			field: gen.Files[len(gen.Files)-1].Messages[0].Fields[1],
+			want:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := fieldOptions(types, tt.field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := castTypeFromField(options); got != tt.want {
+				t.Errorf("castTypeFromField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_castTypeFromField_legacyNames(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(
+		casttest.Message("Attestation",
+			casttest.StringOption(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.LegacyCastTypeNumber, "github.com/prysmaticlabs/go-bitfield.Bitlist"),
+		),
+	))
+	field := gen.Files[len(gen.Files)-1].Messages[0].Fields[0]
+	tests := []struct {
+		name          string
+		legacyNames   bool
+		want          string
+		wantUnmatched protoreflect.Name
+	}{
+		{
+			name:          "full names only",
+			legacyNames:   false,
+			want:          "",
+			wantUnmatched: "cast_type",
+		},
+		{
+			name:        "legacy names",
+			legacyNames: true,
+			want:        "github.com/prysmaticlabs/go-bitfield.Bitlist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types, err := extensionTypes(testExtensions(gen), tt.legacyNames)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options, err := fieldOptions(types, field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := castTypeFromField(options); got != tt.want {
+				t.Errorf("castTypeFromField() = %v, want %v", got, tt.want)
+			}
+			if got := options[0].Unmatched; got != tt.wantUnmatched {
+				t.Errorf("Unmatched = %q, want %q", got, tt.wantUnmatched)
+			}
+			if tt.wantUnmatched != "" && options[0].Tag != "" {
+				t.Errorf("unmatched option is written as struct tag %s", options[0].Tag)
+			}
+			if got := options[0].Unmatched; got != tt.wantUnmatched {
+				t.Errorf("Unmatched = %q, want %q", got, tt.wantUnmatched)
+			}
+			if tt.wantUnmatched != "" && options[0].Tag != "" {
+				t.Errorf("unmatched option is written as struct tag %s", options[0].Tag)
+			}
+		})
+	}
+}
+
+func Test_structTagsFromField(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(
+		casttest.Message("Deposit",
+			casttest.StringOption(casttest.StringOption(casttest.Field("public_key", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SSZSizeNumber, "48"), casttest.SpecNameNumber, "pubkey"),
+			casttest.StringOption(casttest.Field("quoted", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SpecNameNumber, `say "hi"`),
+			casttest.Uint64Option(casttest.Field("limit", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SSZLimitNumber, 2048),
+		),
+	))
+	types, err := extensionTypes(testExtensions(gen), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := gen.Files[len(gen.Files)-1].Messages[0].Fields
+	tests := []struct {
+		name  string
+		field *protogen.Field
+		want  string
+	}{
+		{
+			name:  "string options",
+			field: fields[0],
+			want:  ` spec-name:"pubkey" ssz-size:"48"`,
+		},
+		{
+			name:  "escaped quotes",
+			field: fields[1],
+			want:  ` spec-name:"say \"hi\""`,
+		},
+		{
+			name:  "non-string option",
+			field: fields[2],
+			want:  ` ssz-limit:"2048"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := fieldOptions(types, tt.field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := structTagsFromField(options); got != tt.want {
+				t.Errorf("structTagsFromField() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fieldOptions_decodedOnce(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(
+		casttest.Message("Deposit",
+			casttest.StringOption(casttest.Field("public_key", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SSZSizeNumber, "48"),
+			casttest.Field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		),
+	))
+	types, err := extensionTypes(testExtensions(gen), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := gen.Files[len(gen.Files)-1].Messages[0].Fields
+	first, err := fieldOptions(types, fields[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := fieldOptions(types, fields[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 1 || len(second) != 1 || &first[0] != &second[0] {
+		t.Errorf("fieldOptions() decoded %v and then %v, want the same cached option", first, second)
+	}
+	if first[0].Tag != "ssz-size" {
+		t.Errorf("fieldOptions() tag = %q, want ssz-size", first[0].Tag)
+	}
+	plain, err := fieldOptions(types, fields[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != nil {
+		t.Errorf("fieldOptions() = %v for a field without options, want nil", plain)
+	}
+}
+
+func TestApply_nestedMessages(t *testing.T) {
+	deeper := casttest.Message("Deeper",
+		casttest.CastField(casttest.Field("bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield.Bitvector64"),
+	)
+	inner := casttest.WithNested(casttest.Message("Inner",
+		casttest.StringOption(casttest.CastField(casttest.Field("bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield.Bitlist"), casttest.SSZMaxNumber, "2048"),
+	), deeper)
+	checkpoint := casttest.WithNested(casttest.Message("Checkpoint"), inner)
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.WithNested(casttest.Message("AttestationData"), checkpoint)))
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"func (x *AttestationData_Checkpoint_Inner) GetBits() bitfield.Bitlist {",
+		"func (x *AttestationData_Checkpoint_Inner_Deeper) GetBits() bitfield.Bitvector64 {",
+		`ssz-max:"2048"`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_mapFields(t *testing.T) {
+	message := casttest.Message("ValidatorBits")
+	casttest.StringOption(casttest.StringOption(
+		casttest.AddMapField(message, "bits_by_index", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+		casttest.CastKeyTypeNumber, "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.ValidatorIndex"),
+		casttest.CastValueTypeNumber, "github.com/prysmaticlabs/go-bitfield.Bitlist")
+	casttest.StringOption(
+		casttest.AddMapField(message, "indices", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		casttest.CastValueTypeNumber, "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.ValidatorIndex")
+	gen := casttest.NewPlugin(t, "", casttest.File(message))
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"BitsByIndex map[primitives.ValidatorIndex]bitfield.Bitlist `protobuf:\"bytes,1,rep,name=bits_by_index,",
+		"func (x *ValidatorBits) GetBitsByIndex() map[primitives.ValidatorIndex]bitfield.Bitlist {",
+		"Indices map[string]primitives.ValidatorIndex `protobuf:\"bytes,2,rep,name=indices",
+		"func (x *ValidatorBits) GetIndices() map[string]primitives.ValidatorIndex {",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_scalarKinds(t *testing.T) {
+	tests := []struct {
+		typ  descriptorpb.FieldDescriptorProto_Type
+		want string
+	}{
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_BOOL, want: "return primitives.T(false)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_INT32, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_SINT64, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_FIXED64, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_SFIXED32, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_FLOAT, want: "return primitives.T(0)"},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_STRING, want: `return primitives.T("")`},
+		{typ: descriptorpb.FieldDescriptorProto_TYPE_BYTES, want: "return primitives.T(nil)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			gen := casttest.NewPlugin(t, "", casttest.File(
+				casttest.Message("ScalarCasts", casttest.CastField(casttest.Field("value", 1, tt.typ), "primitives.T")),
+			))
+			content := generateCastedContent(t, gen, Options{})
+			for _, want := range []string{
+				"func (x *ScalarCasts) GetValue() primitives.T {",
+				tt.want,
+			} {
+				if !strings.Contains(content, want) {
+					t.Errorf("generated file does not contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+// TestApply_enumFields checks the rewritten declarations only.
+// TestGenerate_roundTrip in the main package compiles cast enum fields,
+// marshals them and reads them back through their enum descriptor.
+func TestApply_enumFields(t *testing.T) {
+	status := casttest.CastField(casttest.Field("status", 1, descriptorpb.FieldDescriptorProto_TYPE_ENUM), "primitives.ValidatorStatus")
+	status.TypeName = proto.String(".v1.ValidatorStatus")
+	file := casttest.File(casttest.Message("Validator", status))
+	file.EnumType = []*descriptorpb.EnumDescriptorProto{{
+		Name: proto.String("ValidatorStatus"),
+		Value: []*descriptorpb.EnumValueDescriptorProto{
+			{Name: proto.String("UNKNOWN_STATUS"), Number: proto.Int32(0)},
+			{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+		},
+	}}
+	gen := casttest.NewPlugin(t, "", file)
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"Status primitives.ValidatorStatus `protobuf:",
+		"func (x *Validator) GetStatus() primitives.ValidatorStatus {",
+		"return primitives.ValidatorStatus(ValidatorStatus_UNKNOWN_STATUS)",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_proto2Defaults(t *testing.T) {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, castType, defaultValue string) *descriptorpb.FieldDescriptorProto {
+		fd := casttest.CastField(casttest.Field(name, number, typ), castType)
+		fd.DefaultValue = proto.String(defaultValue)
+		return fd
+	}
+	status := field("status", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, "primitives.ValidatorStatus", "ACTIVE")
+	status.TypeName = proto.String(".v1.ValidatorStatus")
+	file := casttest.File(casttest.Message("Checkpoint",
+		field("slot", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64, "primitives.Uint64", "5"),
+		field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "primitives.String", "genesis"),
+		field("root", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES, "primitives.Bytes", "abc"),
+		status,
+	))
+	file.Syntax = proto.String("proto2")
+	file.EnumType = []*descriptorpb.EnumDescriptorProto{{
+		Name: proto.String("ValidatorStatus"),
+		Value: []*descriptorpb.EnumValueDescriptorProto{
+			{Name: proto.String("UNKNOWN_STATUS"), Number: proto.Int32(0)},
+			{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+		},
+	}}
+	gen := casttest.NewPlugin(t, "", file)
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"Slot *primitives.Uint64 `protobuf:",
+		"func (x *Checkpoint) GetSlot() primitives.Uint64 {\n\tif x != nil && x.Slot != nil {\n\t\treturn *x.Slot\n\t}\n\treturn primitives.Uint64(Default_Checkpoint_Slot)\n}",
+		"return primitives.String(Default_Checkpoint_Name)\n}",
+		"return primitives.Bytes(append([]byte(nil), Default_Checkpoint_Root...))\n}",
+		"return primitives.ValidatorStatus(Default_Checkpoint_Status)\n}",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_optionalFields(t *testing.T) {
+	epoch := casttest.CastField(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "primitives.Epoch")
+	epoch.Proto3Optional = proto.Bool(true)
+	epoch.OneofIndex = proto.Int32(0)
+	checkpoint := casttest.Message("Checkpoint", epoch)
+	checkpoint.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_epoch")}}
+	gen := casttest.NewPlugin(t, "", casttest.File(checkpoint))
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"Epoch *primitives.Epoch `protobuf:",
+		"func (x *Checkpoint) GetEpoch() primitives.Epoch {\n\tif x != nil && x.Epoch != nil {\n\t\treturn *x.Epoch\n\t}\n\treturn primitives.Epoch(0)\n}",
+		"func (x *Checkpoint) HasEpoch() bool {\n\treturn x != nil && x.Epoch != nil\n}",
+		"func (x *Checkpoint) ClearEpoch() {\n\tif x != nil {\n\t\tx.Epoch = nil\n\t}\n}",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_oneofFields(t *testing.T) {
+	epoch := casttest.CastField(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "primitives.Epoch")
+	epoch.OneofIndex = proto.Int32(0)
+	bits := casttest.CastField(casttest.Field("bits", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist")
+	bits.OneofIndex = proto.Int32(0)
+	filter := casttest.Message("Filter", epoch, bits)
+	filter.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("query_filter")}}
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.WithNested(casttest.Message("ListAttestationsRequest"), filter)))
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"func (x *ListAttestationsRequest_Filter) GetEpoch() primitives.Epoch {\n\tif x, ok := x.GetQueryFilter().(*ListAttestationsRequest_Filter_Epoch); ok {\n\t\treturn x.Epoch\n\t}\n\treturn primitives.Epoch(0)\n}",
+		"func (x *ListAttestationsRequest_Filter) GetBits() bitfield.Bitlist {\n\tif x, ok := x.GetQueryFilter().(*ListAttestationsRequest_Filter_Bits); ok {\n\t\treturn x.Bits\n\t}\n\treturn bitfield.Bitlist(nil)\n}",
+		"Epoch primitives.Epoch `protobuf:\"varint,1,opt,name=epoch,proto3,oneof\"",
+		"Bits bitfield.Bitlist `protobuf:\"bytes,2,opt,name=bits,proto3,oneof\"",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func TestApply_setters(t *testing.T) {
+	epoch := casttest.CastField(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "primitives.Epoch")
+	epoch.Proto3Optional = proto.Bool(true)
+	epoch.OneofIndex = proto.Int32(1)
+	filter := casttest.CastField(casttest.Field("filter", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist")
+	filter.OneofIndex = proto.Int32(0)
+	request := casttest.Message("ListAttestationsRequest", epoch, filter,
+		casttest.CastField(casttest.Field("bits", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"),
+		casttest.Field("page_size", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+	)
+	request.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("query_filter")}, {Name: proto.String("_epoch")}}
+	gen := casttest.NewPlugin(t, "", casttest.File(request))
+
+	content := generateCastedContent(t, gen, Options{Setters: true})
+	for _, want := range []string{
+		"func (x *ListAttestationsRequest) SetEpoch(v primitives.Epoch) {\n\tif x != nil {\n\t\tx.Epoch = &v\n\t}\n}",
+		"func (x *ListAttestationsRequest) SetFilter(v bitfield.Bitlist) {\n\tif x != nil {\n\t\tx.QueryFilter = &ListAttestationsRequest_Filter{Filter: v}\n\t}\n}",
+		"func (x *ListAttestationsRequest) SetBits(v bitfield.Bitlist) {\n\tif x != nil {\n\t\tx.Bits = v\n\t}\n}",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+	if strings.Contains(content, "SetPageSize") {
+		t.Error("generated a setter for a field that is not cast")
+	}
+}
+
+func TestApply_formatted(t *testing.T) {
+	history := casttest.CastField(casttest.Field("history", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Epoch")
+	history.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	epoch := casttest.CastField(casttest.Field("epoch", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Epoch")
+	epoch.Proto3Optional = proto.Bool(true)
+	epoch.OneofIndex = proto.Int32(0)
+	checkpoint := casttest.Message("Checkpoint", history, epoch)
+	checkpoint.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_epoch")}}
+	gen := casttest.NewPlugin(t, "", casttest.File(checkpoint))
+	f := gen.Files[len(gen.Files)-1]
+	if err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	content := resp.File[len(resp.File)-1].GetContent()
+
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != content {
+		t.Error("generated file is not gofmt-clean")
+	}
+}
+
+func Test_fieldRewrite_types(t *testing.T) {
+	epoch := &typeName{Package: "primitives", Name: "Epoch"}
+	tests := []struct {
+		name       string
+		rewrite    *fieldRewrite
+		wantStruct string
+		wantGetter string
+		value      string
+		wantValue  string
+	}{
+		{
+			name:       "scalar",
+			rewrite:    &fieldRewrite{CastType: epoch},
+			wantStruct: "primitives.Epoch",
+			wantGetter: "primitives.Epoch",
+			value:      "0",
+			wantValue:  "primitives.Epoch(0)",
+		},
+		{
+			name:       "optional",
+			rewrite:    &fieldRewrite{CastType: epoch, Pointer: true},
+			wantStruct: "*primitives.Epoch",
+			wantGetter: "primitives.Epoch",
+			value:      "Default_Checkpoint_Epoch",
+			wantValue:  "primitives.Epoch(Default_Checkpoint_Epoch)",
+		},
+		{
+			name:       "repeated",
+			rewrite:    &fieldRewrite{CastType: epoch, Repeated: true},
+			wantStruct: "[]primitives.Epoch",
+			wantGetter: "[]primitives.Epoch",
+			value:      "nil",
+			wantValue:  "[]primitives.Epoch(nil)",
+		},
+		{
+			name:       "local string",
+			rewrite:    &fieldRewrite{CastType: &typeName{Name: "Name"}},
+			wantStruct: "Name",
+			wantGetter: "Name",
+			value:      `""`,
+			wantValue:  `Name("")`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := types.ExprString(tt.rewrite.structType()); got != tt.wantStruct {
+				t.Errorf("structType() = %v, want %v", got, tt.wantStruct)
+			}
+			if got := types.ExprString(tt.rewrite.getterType()); got != tt.wantGetter {
+				t.Errorf("getterType() = %v, want %v", got, tt.wantGetter)
+			}
+			value, err := parser.ParseExpr(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := types.ExprString(tt.rewrite.castDefault(value)); got != tt.wantValue {
+				t.Errorf("castDefault(%s) = %v, want %v", tt.value, got, tt.wantValue)
+			}
+			if _, ok := tt.rewrite.structType().(*ast.Ident); ok && strings.ContainsAny(tt.wantStruct, ".*[") {
+				t.Errorf("structType() is an identifier %q", tt.wantStruct)
+			}
+		})
+	}
+}
+
+func TestApply_imports(t *testing.T) {
+	bytesField := func(name string, number int32, castType string) *descriptorpb.FieldDescriptorProto {
+		return casttest.CastField(casttest.Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_BYTES), castType)
+	}
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.Message("Imports",
+		bytesField("existing", 1, "google.golang.org/protobuf/reflect/protoreflect.RawFields"),
+		bytesField("local", 2, "github.com/prysmaticlabs/protoc-gen-go-cast/test.Bits"),
+		bytesField("first", 3, "github.com/a/primitives.Bytes"),
+		bytesField("second", 4, "github.com/b/primitives.Bytes"),
+		bytesField("again", 5, "github.com/a/primitives.Bytes"),
+		bytesField("aliased", 6, "github.com/prysmaticlabs/go-bitfield;bf.Bitlist"),
+		bytesField("bits", 7, "github.com/prysmaticlabs/go-bitfield.Bitlist"),
+		bytesField("versioned", 8, "github.com/c/primitives/v2.Bytes"),
+		// x is the receiver of every generated method.
+		bytesField("shadowed", 9, "github.com/a/x.Bytes"),
+	)))
+
+	content := generateCastedContent(t, gen, Options{})
+	for _, want := range []string{
+		"\tprotoreflect \"google.golang.org/protobuf/reflect/protoreflect\"\n",
+		"\tprimitives \"github.com/a/primitives\"\n",
+		"\tprimitives1 \"github.com/b/primitives\"\n",
+		"\tbf \"github.com/prysmaticlabs/go-bitfield\"\n",
+		"Existing protoreflect.RawFields `",
+		"Local Bits `",
+		"First primitives.Bytes `",
+		"Second primitives1.Bytes `",
+		"Again primitives.Bytes `",
+		"Aliased bf.Bitlist `",
+		"\tbitfield \"github.com/prysmaticlabs/go-bitfield\"\n",
+		"\tprimitives2 \"github.com/c/primitives/v2\"\n",
+		"\tx1 \"github.com/a/x\"\n",
+		"Bits bitfield.Bitlist `",
+		"Versioned primitives2.Bytes `",
+		"Shadowed x1.Bytes `",
+		"func (x *Imports) GetShadowed() x1.Bytes {",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+	if got := strings.Count(content, `"github.com/a/primitives"`); got != 1 {
+		t.Errorf("github.com/a/primitives is imported %d times, want 1", got)
+	}
+	if strings.Contains(content, reservedImportPath) {
+		t.Errorf("generated file imports a reserved path:\n%s", content)
+	}
+}
+
+func TestApply_aliasCollision(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.Message("Imports",
+		casttest.CastField(casttest.Field("aliased", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield;protoimpl.Bitlist"),
+	)))
+	f := gen.Files[len(gen.Files)-1]
+
+	err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{})
+	want := `test.proto: field v1.Imports.aliased: import alias protoimpl of "github.com/prysmaticlabs/go-bitfield" is already used for "google.golang.org/protobuf/runtime/protoimpl"`
+	if err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %v", err, want)
+	}
+}
+
+func TestApply_unmatchedOption(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.Message("Attestation",
+		casttest.StringOption(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.LegacyCastTypeNumber, "github.com/prysmaticlabs/go-bitfield.Bitlist"),
+	)))
+	f := gen.Files[len(gen.Files)-1]
+
+	err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{})
+	want := "test.proto: field v1.Attestation.aggregation_bits: (v1.cast_type) is not the (cast_type) option of cast/options.proto and is ignored: import cast/options.proto and set (cast.v1.cast_type), or set legacy_option_names=true"
+	if err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %v", err, want)
+	}
+}
+
+func TestApply_annotations(t *testing.T) {
+	bits := casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield.Bitlist")
+	epoch := casttest.CastField(casttest.Field("epoch", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "primitives.Epoch")
+	epoch.Proto3Optional = proto.Bool(true)
+	epoch.OneofIndex = proto.Int32(0)
+	attestation := casttest.Message("Attestation", casttest.StringOption(bits, casttest.SSZMaxNumber, "2048"), epoch)
+	attestation.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_epoch")}}
+	gen := casttest.NewPlugin(t, "annotate_code=true", casttest.File(attestation))
+	f := gen.Files[len(gen.Files)-1]
+	if err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	files := make(map[string]string)
+	for _, file := range resp.File {
+		files[file.GetName()] = file.GetContent()
+	}
+	content := files["github.com/prysmaticlabs/protoc-gen-go-cast/test/test.pb.go"]
+	info := &descriptorpb.GeneratedCodeInfo{}
+	if err := prototext.Unmarshal([]byte(files["github.com/prysmaticlabs/protoc-gen-go-cast/test/test.pb.go.meta"]), info); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string][]string)
+	for _, annotation := range info.Annotation {
+		path := fmt.Sprint(annotation.Path)
+		got[path] = append(got[path], content[annotation.GetBegin():annotation.GetEnd()])
+	}
+	want := map[string][]string{
+		"[4 0]":     {"Attestation"},
+		"[4 0 2 0]": {"AggregationBits", "GetAggregationBits"},
+		"[4 0 2 1]": {"Epoch", "GetEpoch", "HasEpoch", "ClearEpoch"},
+	}
+	for path, symbols := range want {
+		if !reflect.DeepEqual(got[path], symbols) {
+			t.Errorf("annotations of %s = %q, want %q", path, got[path], symbols)
+		}
+	}
+}
+
+func TestApply_header(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(casttest.Message("Attestation",
+		casttest.StringOption(casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"), casttest.SSZMaxNumber, "2048"),
+		casttest.StringOption(casttest.Field("signature", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SSZSizeNumber, "96"),
+		casttest.Field("slot", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+	)))
+	gen.Request.CompilerVersion = &pluginpb.Version{Major: proto.Int32(3), Minor: proto.Int32(15), Patch: proto.Int32(8)}
+
+	content := generateCastedContent(t, gen, Options{})
+	want := "// Code generated by protoc-gen-go-cast. DO NOT EDIT.\n" +
+		"// versions:\n" +
+		"// protoc-gen-go-cast " + Version() + "\n" +
+		"// protoc-gen-go " + gengoVersion(t) + "\n" +
+		"// protoc v3.15.8\n" +
+		"// source: test.proto\n" +
+		"// summary: 1 field cast, 2 fields tagged\n" +
+		"\npackage test\n"
+	if !strings.HasPrefix(content, want) {
+		t.Errorf("generated file starts with\n%s\nwant\n%s", content[:len(want)], want)
+	}
+}
+
+// gengoVersion returns the version of protoc-gen-go gengo writes in the
+// header of the files it generates.
+func gengoVersion(t *testing.T) string {
+	t.Helper()
+	gen := casttest.NewPlugin(t, "", casttest.File())
+	content, err := gengo.GenerateFile(gen, gen.Files[len(gen.Files)-1]).Content()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`// \tprotoc-gen-go +(\S+)\n`).FindSubmatch(content)
+	if m == nil {
+		t.Fatalf("no protoc-gen-go version in\n%s", content)
+	}
+	return string(m[1])
+}
+
+func TestApply_collision(t *testing.T) {
+	gen := casttest.NewPlugin(t, "", casttest.File(
+		casttest.WithNested(casttest.Message("Foo"), casttest.Message("Bar",
+			casttest.CastField(casttest.Field("baz", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"),
+		)),
+		casttest.Message("Foo_Bar",
+			casttest.CastField(casttest.Field("baz", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"),
+		),
+	))
+	f := gen.Files[len(gen.Files)-1]
+
+	err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{})
+	want := "test.proto: field v1.Foo_Bar.baz: generates struct field Foo_Bar.Baz, as does v1.Foo.Bar.baz"
+	if err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %v", err, want)
+	}
+}
+
+func TestApply_errorLocation(t *testing.T) {
+	file := casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield."),
+	))
+	file.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{4, 0, 2, 0}, Span: []int32{6, 2, 40}},
+		},
+	}
+	gen := casttest.NewPlugin(t, "", file)
+	f := gen.Files[len(gen.Files)-1]
+
+	err := Apply(gen, f, gengo.GenerateFile(gen, f), Options{})
+	want := `test.proto:7:3: field v1.Attestation.aggregation_bits: invalid (cast_type): "github.com/prysmaticlabs/go-bitfield." does not end in a Go type name`
+	if err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %v", err, want)
+	}
+}
+
+func TestCaster_LoadPlan(t *testing.T) {
+	file := casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("slot", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Slot"),
+	))
+	plainGen := casttest.NewPlugin(t, "", file)
+	gengo.GenerateFile(plainGen, plainGen.Files[len(plainGen.Files)-1])
+	plain := plainGen.Response().File[0].GetContent()
+	body := plain[strings.Index(plain, "// source: "):]
+
+	gen := casttest.NewPlugin(t, "", file)
+	f := gen.Files[len(gen.Files)-1]
+	c, err := NewCaster(gen, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.LoadPlan(f, []byte(body)); err == nil || err.Error() != "no // Code generated ... DO NOT EDIT. header" {
+		t.Errorf("LoadPlan() error = %v, want a missing header error", err)
+	}
+	// Any generator may have written the file, and its versions are kept.
+	header := "// Code generated by protoc-gen-go-custom. DO NOT EDIT.\n" +
+		"// versions:\n" +
+		"// \tprotoc-gen-go v1.25.0\n" +
+		"// \tprotoc        v3.12.4\n"
+	plan, err := c.LoadPlan(f, []byte(header+body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(plan); err != nil {
+		t.Fatal(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	want := "// Code generated by protoc-gen-go-cast. DO NOT EDIT.\n" +
+		"// versions:\n" +
+		"// \tprotoc-gen-go-cast " + Version() + "\n" +
+		"// \tprotoc-gen-go      v1.25.0\n" +
+		"// \tprotoc             v3.12.4\n" +
+		"// source: test.proto\n"
+	if content := resp.File[0].GetContent(); !strings.HasPrefix(content, want) {
+		t.Errorf("casted file starts with\n%s\nwant\n%s", content[:len(want)], want)
+	}
+}
+
+func TestCaster_Lint(t *testing.T) {
+	root := casttest.CastField(casttest.Field("root", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE), "github.com/a/primitives.Root")
+	root.TypeName = proto.String(".v1.Other")
+	message := casttest.Message("Checkpoint",
+		root,
+		casttest.StringOption(casttest.Field("epoch", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64), casttest.CastKeyTypeNumber, "github.com/a/primitives.Epoch"),
+		casttest.CastField(casttest.Field("signature", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives."),
+		casttest.StringOption(casttest.StringOption(casttest.Field("names", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING), 50020, "a"), casttest.SpecNameNumber, "names"),
+		casttest.CastField(casttest.Field("slot", 5, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Slot"),
+		casttest.StringOption(casttest.Field("legacy", 8, descriptorpb.FieldDescriptorProto_TYPE_UINT64), casttest.LegacyCastTypeNumber, "github.com/a/primitives.Slot"),
+	)
+	casttest.CastField(casttest.AddMapField(message, "roots", 6, descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives.Roots")
+	casttest.StringOption(casttest.AddMapField(message, "others", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE), casttest.CastValueTypeNumber, "github.com/a/primitives.Other")
+	message.NestedType[len(message.NestedType)-1].Field[1].TypeName = proto.String(".v1.Other")
+	file := casttest.File(message, casttest.Message("Other"))
+	names := casttest.Field("spec_names", 50020, descriptorpb.FieldDescriptorProto_TYPE_STRING)
+	names.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	names.Extendee = proto.String(".google.protobuf.FieldOptions")
+	file.Extension = append(file.Extension, names)
+	file.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{
+			{Path: []int32{4, 0, 2, 0}, Span: []int32{6, 2, 40}},
+		},
+	}
+	gen := casttest.NewPlugin(t, "", file)
+	c, err := NewCaster(gen, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags, err := c.Lint(gen.Files[len(gen.Files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`test.proto:7:3: error: field v1.Checkpoint.root: (cast_type) "github.com/a/primitives.Root" on a message field: only scalar, string, bytes and enum fields can be cast`,
+		`test.proto: warning: field v1.Checkpoint.epoch: (cast_key_type) is ignored on fields that are not maps`,
+		`test.proto: error: field v1.Checkpoint.signature: invalid (cast_type): "github.com/a/primitives." does not end in a Go type name`,
+		`test.proto: warning: field v1.Checkpoint.names: (v1.spec_names) is not a scalar and is not written as a struct tag`,
+		`test.proto: error: field v1.Checkpoint.legacy: (v1.cast_type) is not the (cast_type) option of cast/options.proto and is ignored: import cast/options.proto and set (cast.v1.cast_type), or set legacy_option_names=true`,
+		`test.proto: warning: field v1.Checkpoint.roots: (cast_type) is ignored on map fields, which are cast with (cast_key_type) and (cast_value_type)`,
+		`test.proto: error: field v1.Checkpoint.others: (cast_value_type) "github.com/a/primitives.Other" on a map of messages: only scalar, string, bytes and enum values can be cast`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %s, want %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCaster_Lint_legacyNames(t *testing.T) {
+	root := casttest.StringOption(casttest.Field("root", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE), casttest.LegacyCastTypeNumber, "github.com/a/primitives.Root")
+	root.TypeName = proto.String(".v1.Other")
+	message := casttest.Message("Checkpoint",
+		root,
+		casttest.StringOption(casttest.Field("signature", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.LegacyCastTypeNumber, "github.com/a/primitives."),
+		casttest.StringOption(casttest.Field("slot", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64), casttest.LegacyCastTypeNumber, "github.com/a/primitives.Slot"),
+	)
+	gen := casttest.NewPlugin(t, "", casttest.File(message, casttest.Message("Other")))
+	c, err := NewCaster(gen, Options{LegacyOptionNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags, err := c.Lint(gen.Files[len(gen.Files)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`test.proto: error: field v1.Checkpoint.root: (cast_type) "github.com/a/primitives.Root" on a message field: only scalar, string, bytes and enum fields can be cast`,
+		`test.proto: error: field v1.Checkpoint.signature: invalid (cast_type): "github.com/a/primitives." does not end in a Go type name`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %s, want %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCaster_CheckTypes(t *testing.T) {
+	primitives := types.NewPackage("github.com/a/primitives", "primitives")
+	for name, underlying := range map[string]types.Type{
+		"Epoch": types.Typ[types.Uint64],
+		"Root":  types.NewSlice(types.Universe.Lookup("byte").Type()),
+		"root":  types.NewSlice(types.Universe.Lookup("byte").Type()),
+	} {
+		typeName := types.NewTypeName(token.NoPos, primitives, name, nil)
+		types.NewNamed(typeName, underlying, nil)
+		primitives.Scope().Insert(typeName)
+	}
+	primitives.Scope().Insert(types.NewFunc(token.NoPos, primitives, "Slot", types.NewSignature(nil, nil, nil, false)))
+
+	message := casttest.Message("Checkpoint",
+		casttest.CastField(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Epoch"),
+		casttest.CastField(casttest.Field("root", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives;p.Root"),
+		casttest.CastField(casttest.Field("typo", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Epch"),
+		casttest.CastField(casttest.Field("signature", 4, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives.Epoch"),
+		casttest.CastField(casttest.Field("unexported", 5, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives.root"),
+		casttest.CastField(casttest.Field("slot", 6, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Slot"),
+		casttest.CastField(casttest.Field("name", 7, descriptorpb.FieldDescriptorProto_TYPE_UINT32), "string"),
+		casttest.CastField(casttest.Field("local", 8, descriptorpb.FieldDescriptorProto_TYPE_UINT32), "Local"),
+		casttest.CastField(casttest.Field("missing", 9, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/b/missing.Bits"),
+	)
+	casttest.StringOption(casttest.StringOption(
+		casttest.AddMapField(message, "roots", 10, descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+		casttest.CastKeyTypeNumber, "github.com/a/primitives.Epoch"),
+		casttest.CastValueTypeNumber, "github.com/a/primitives.Root")
+	gen := casttest.NewPlugin(t, "", casttest.File(message))
+	f := gen.Files[len(gen.Files)-1]
+	c, err := NewCaster(gen, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := c.CastImportPaths(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"github.com/a/primitives", "github.com/b/missing"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("CastImportPaths() = %v, want %v", paths, want)
+	}
+
+	diags, err := c.CheckTypes(f, map[string]*types.Package{"github.com/a/primitives": primitives})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`test.proto: error: field v1.Checkpoint.typo: (cast_type) "github.com/a/primitives.Epch": package github.com/a/primitives declares no Epch`,
+		`test.proto: error: field v1.Checkpoint.signature: (cast_type) "github.com/a/primitives.Epoch": underlying type is uint64, want []byte`,
+		`test.proto: error: field v1.Checkpoint.unexported: (cast_type) "github.com/a/primitives.root": root is not exported by package github.com/a/primitives`,
+		`test.proto: error: field v1.Checkpoint.slot: (cast_type) "github.com/a/primitives.Slot": Slot is not a type`,
+		`test.proto: error: field v1.Checkpoint.name: (cast_type) "string": underlying type is string, want uint32`,
+		`test.proto: warning: field v1.Checkpoint.missing: (cast_type) "github.com/b/missing.Bits": package github.com/b/missing is not available, so the type is not checked`,
+		`test.proto: error: field v1.Checkpoint.roots: (cast_value_type) "github.com/a/primitives.Root": underlying type is []byte, want string`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckTypes() = %s, want %s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCaster_Report(t *testing.T) {
+	epoch := casttest.CastField(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/a/primitives.Epoch")
+	epoch.Proto3Optional = proto.Bool(true)
+	epoch.OneofIndex = proto.Int32(0)
+	roots := casttest.CastField(casttest.Field("roots", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/protoc-gen-go-cast/test.Root")
+	roots.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	checkpoint := casttest.Message("Checkpoint",
+		epoch,
+		roots,
+		casttest.StringOption(casttest.Field("name", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING), casttest.SpecNameNumber, "checkpoint_name"),
+		casttest.Field("plain", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+	)
+	checkpoint.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_epoch")}}
+	casttest.StringOption(casttest.StringOption(
+		casttest.AddMapField(checkpoint, "balances", 5, descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		casttest.CastKeyTypeNumber, "github.com/a/primitives.ValidatorIndex"),
+		casttest.CastValueTypeNumber, "github.com/a/primitives.Gwei")
+	gen := casttest.NewPlugin(t, "", casttest.File(checkpoint))
+	f := gen.Files[len(gen.Files)-1]
+	c, err := NewCaster(gen, Options{ImportRewriteFunc: func(path protogen.GoImportPath) protogen.GoImportPath {
+		return "vendor/" + path
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := c.BuildPlan(f, gengo.GenerateFile(gen, f))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.Report(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "source": "test.proto",
+  "go_import_path": "github.com/prysmaticlabs/protoc-gen-go-cast/test",
+  "fields": [
+    {
+      "field": "v1.Checkpoint.epoch",
+      "location": "test.proto",
+      "go_field": "Checkpoint.Epoch",
+      "go_type": "*uint64",
+      "cast_type": "*github.com/a/primitives.Epoch",
+      "imports": [
+        {
+          "name": "primitives",
+          "path": "vendor/github.com/a/primitives"
+        }
+      ]
+    },
+    {
+      "field": "v1.Checkpoint.roots",
+      "location": "test.proto",
+      "go_field": "Checkpoint.Roots",
+      "go_type": "[][]byte",
+      "cast_type": "[]Root"
+    },
+    {
+      "field": "v1.Checkpoint.name",
+      "location": "test.proto",
+      "go_field": "Checkpoint.Name",
+      "go_type": "string",
+      "tags": "spec-name:\"checkpoint_name\""
+    },
+    {
+      "field": "v1.Checkpoint.balances",
+      "location": "test.proto",
+      "go_field": "Checkpoint.Balances",
+      "go_type": "map[uint64]uint64",
+      "cast_type": "map[github.com/a/primitives.ValidatorIndex]github.com/a/primitives.Gwei",
+      "imports": [
+        {
+          "name": "primitives",
+          "path": "vendor/github.com/a/primitives"
+        }
+      ]
+    }
+  ]
+}
+`
+	if string(report) != want {
+		t.Errorf("Report() = %s, want %s", report, want)
+	}
+}
+
+// generateCastedContent runs gengo and the cast rewrite over every generated
+// file of gen and returns the content of the last one, with runs of spaces
+// used for alignment collapsed. Indentation is kept.
+func generateCastedContent(t *testing.T, gen *protogen.Plugin, opts Options) string {
+	t.Helper()
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		if err := Apply(gen, f, gengo.GenerateFile(gen, f), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	return alignment.ReplaceAllString(resp.File[len(resp.File)-1].GetContent(), "$1 ")
+}
+
+var alignment = regexp.MustCompile(`(\S)[ \t]+`)
+
+func testExtensions(gen *protogen.Plugin) []*protogen.Extension {
+	var allExtensions []*protogen.Extension
+	for _, f := range gen.Files {
+		allExtensions = append(allExtensions, f.Extensions...)
+	}
+	return allExtensions
+}
+
+// BenchmarkBuildCastPlan measures reading the cast options of a large
+// corpus and planning its rewrites, as one run of the plugin does.
+func BenchmarkBuildCastPlan(b *testing.B) {
+	gen, err := protogen.Options{}.New(casttest.Corpus(50, 40, 20))
+	if err != nil {
+		b.Fatal(err)
+	}
+	allExtensions := testExtensions(gen)
+	gennedFiles := make(map[*protogen.File]*protogen.GeneratedFile)
+	for _, f := range gen.Files {
+		if f.Generate {
+			gennedFiles[f] = gengo.GenerateFile(gen, f)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		types, err := extensionTypes(allExtensions, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+			if _, err := buildPlan(gennedFiles[f], f, types, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkFieldOptions measures decoding the options of every field of a
+// large corpus. The uncached baseline indexes the extensions once per file
+// and re-parses the options of every field, as plans did before options were
+// cached, so the gain of caching can be measured within one run.
+func BenchmarkFieldOptions(b *testing.B) {
+	gen, err := protogen.Options{}.New(casttest.Corpus(50, 40, 20))
+	if err != nil {
+		b.Fatal(err)
+	}
+	allExtensions := testExtensions(gen)
+	var files [][]*protogen.Field
+	for _, f := range gen.Files {
+		if f.Generate {
+			files = append(files, appendFields(nil, f.Messages))
+		}
+	}
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			types, err := extensionTypes(allExtensions, false)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, fields := range files {
+				for _, field := range fields {
+					if _, err := fieldOptions(types, field); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, fields := range files {
+				types, err := extensionTypes(allExtensions, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, field := range fields {
+					if _, err := uncachedFieldOptions(types, field); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		}
+	})
+}
+
+// uncachedFieldOptions decodes the options of field the way fieldOptions did
+// before decoded options were cached: by marshaling all of them and parsing
+// them back against types.
+func uncachedFieldOptions(types *optionTypes, field *protogen.Field) ([]customOption, error) {
+	options, ok := field.Desc.Options().(*descriptorpb.FieldOptions)
+	if !ok || options == nil {
+		return nil, nil
+	}
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	decoded := &descriptorpb.FieldOptions{}
+	if err := (proto.UnmarshalOptions{Resolver: types.types}).Unmarshal(raw, decoded); err != nil {
+		return nil, err
+	}
+	var opts []customOption
+	decoded.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsExtension() {
+			opts = append(opts, types.option(fd, v))
+		}
+		return true
+	})
+	sort.Slice(opts, func(i, j int) bool {
+		return opts[i].Desc.Number() < opts[j].Desc.Number()
+	})
+	return opts, nil
+}
+
+// appendFields appends the fields of messages, at any depth.
+func appendFields(fields []*protogen.Field, messages []*protogen.Message) []*protogen.Field {
+	for _, message := range messages {
+		fields = append(fields, message.Fields...)
+		fields = appendFields(fields, message.Messages)
+	}
+	return fields
+}
@@ -0,0 +1,177 @@
+// Options understood by protoc-gen-go-cast.
+//
+// Import this file instead of declaring the options in your own protos:
+//
+//   import "cast/options.proto";
+//
+//   message Attestation {
+//     bytes aggregation_bits = 1 [(cast.v1.cast_type) = "github.com/prysmaticlabs/go-bitfield.Bitlist"];
+//   }
+//
+// The extension numbers below are fixed for this version of the package and
+// will not be reused for other options.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        (unknown)
+// source: cast/options.proto
+
+package castpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+var file_cast_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50600,
+		Name:          "cast.v1.cast_type",
+		Tag:           "bytes,50600,opt,name=cast_type",
+		Filename:      "cast/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50601,
+		Name:          "cast.v1.cast_key_type",
+		Tag:           "bytes,50601,opt,name=cast_key_type",
+		Filename:      "cast/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.FieldOptions)(nil),
+		ExtensionType: (*string)(nil),
+		Field:         50602,
+		Name:          "cast.v1.cast_value_type",
+		Tag:           "bytes,50602,opt,name=cast_value_type",
+		Filename:      "cast/options.proto",
+	},
+	{
+		ExtendedType:  (*descriptorpb.MessageOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50610,
+		Name:          "cast.v1.cast_setters",
+		Tag:           "varint,50610,opt,name=cast_setters",
+		Filename:      "cast/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.FieldOptions.
+var (
+	// Go type of the field, written as the import path followed by a dot and
+	// the type name, e.g. "github.com/prysmaticlabs/go-bitfield.Bitlist".
+	//
+	// The package is imported under the name goimports assumes for it: the last
+	// element of its import path, skipping a major version suffix such as /v2,
+	// without a go- prefix and cut at the first character that cannot be in a Go
+	// name. So "github.com/prysmaticlabs/go-bitfield" is imported as bitfield and
+	// "github.com/a/primitives/v2" as primitives. A number is added to a name
+	// that another import of the generated file uses, or that the generated code
+	// declares locally, such as x, b, i, p, v, mi, ms and out. A package named
+	// after the last element of its path reuses an import the generated file
+	// already has. Add an alias after a semicolon to pick the name yourself, e.g.
+	// "github.com/prysmaticlabs/go-bitfield;bf.Bitlist".
+	//
+	// optional string cast_type = 50600;
+	E_CastType = &file_cast_options_proto_extTypes[0]
+	// Go type of the keys of a map field.
+	//
+	// optional string cast_key_type = 50601;
+	E_CastKeyType = &file_cast_options_proto_extTypes[1]
+	// Go type of the values of a map field.
+	//
+	// optional string cast_value_type = 50602;
+	E_CastValueType = &file_cast_options_proto_extTypes[2]
+)
+
+// Extension fields to descriptorpb.MessageOptions.
+var (
+	// Generate typed setters for the cast fields of the message.
+	//
+	// optional bool cast_setters = 50610;
+	E_CastSetters = &file_cast_options_proto_extTypes[3]
+)
+
+var File_cast_options_proto protoreflect.FileDescriptor
+
+var file_cast_options_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x63, 0x61, 0x73, 0x74, 0x2f, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x63, 0x61, 0x73, 0x74, 0x2e, 0x76, 0x31, 0x1a, 0x20, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3a,
+	0x3c, 0x0a, 0x09, 0x63, 0x61, 0x73, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46,
+	0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa8, 0x8b, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x61, 0x73, 0x74, 0x54, 0x79, 0x70, 0x65, 0x3a, 0x43, 0x0a,
+	0x0d, 0x63, 0x61, 0x73, 0x74, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1d,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xa9, 0x8b,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x61, 0x73, 0x74, 0x4b, 0x65, 0x79, 0x54, 0x79,
+	0x70, 0x65, 0x3a, 0x47, 0x0a, 0x0f, 0x63, 0x61, 0x73, 0x74, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x5f, 0x74, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0xaa, 0x8b, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x61,
+	0x73, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x3a, 0x44, 0x0a, 0x0c, 0x63,
+	0x61, 0x73, 0x74, 0x5f, 0x73, 0x65, 0x74, 0x74, 0x65, 0x72, 0x73, 0x12, 0x1f, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xb2, 0x8b, 0x03,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x63, 0x61, 0x73, 0x74, 0x53, 0x65, 0x74, 0x74, 0x65, 0x72,
+	0x73, 0x42, 0x39, 0x5a, 0x37, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x70, 0x72, 0x79, 0x73, 0x6d, 0x61, 0x74, 0x69, 0x63, 0x6c, 0x61, 0x62, 0x73, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x67, 0x6f, 0x2d, 0x63, 0x61, 0x73, 0x74,
+	0x2f, 0x63, 0x61, 0x73, 0x74, 0x2f, 0x63, 0x61, 0x73, 0x74, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var file_cast_options_proto_goTypes = []interface{}{
+	(*descriptorpb.FieldOptions)(nil),   // 0: google.protobuf.FieldOptions
+	(*descriptorpb.MessageOptions)(nil), // 1: google.protobuf.MessageOptions
+}
+var file_cast_options_proto_depIdxs = []int32{
+	0, // 0: cast.v1.cast_type:extendee -> google.protobuf.FieldOptions
+	0, // 1: cast.v1.cast_key_type:extendee -> google.protobuf.FieldOptions
+	0, // 2: cast.v1.cast_value_type:extendee -> google.protobuf.FieldOptions
+	1, // 3: cast.v1.cast_setters:extendee -> google.protobuf.MessageOptions
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	0, // [0:4] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_cast_options_proto_init() }
+func file_cast_options_proto_init() {
+	if File_cast_options_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_cast_options_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   0,
+			NumExtensions: 4,
+			NumServices:   0,
+		},
+		GoTypes:           file_cast_options_proto_goTypes,
+		DependencyIndexes: file_cast_options_proto_depIdxs,
+		ExtensionInfos:    file_cast_options_proto_extTypes,
+	}.Build()
+	File_cast_options_proto = out.File
+	file_cast_options_proto_rawDesc = nil
+	file_cast_options_proto_goTypes = nil
+	file_cast_options_proto_depIdxs = nil
+}
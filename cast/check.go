@@ -0,0 +1,146 @@
+package cast
+
+import (
+	"fmt"
+	"go/types"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// castUse is a cast type set on a field, along with the Go type gengo
+// generates for the values it replaces.
+type castUse struct {
+	Field    *protogen.Field
+	Option   protoreflect.Name
+	CastType string
+	Ref      castTypeRef
+	GoType   types.Type
+}
+
+// CastImportPaths returns the import paths of the packages that declare the
+// cast types of file, in the order they are first used. Types of the
+// generated package itself are left out.
+func (c *Caster) CastImportPaths(file *protogen.File) ([]string, error) {
+	uses, err := castUses(nil, file, file.Messages, c.types)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, use := range uses {
+		if use.Ref.ImportPath == "" || seen[use.Ref.ImportPath] {
+			continue
+		}
+		seen[use.Ref.ImportPath] = true
+		paths = append(paths, use.Ref.ImportPath)
+	}
+	return paths, nil
+}
+
+// CheckTypes checks that the cast types of file are declared in pkgs, which
+// holds type-checked packages by import path, and that their underlying type
+// is the Go type gengo generates for the field, such as []byte for bytes
+// fields. Cast types of packages missing from pkgs are reported as warnings,
+// and types of the generated package itself are not checked.
+func (c *Caster) CheckTypes(file *protogen.File, pkgs map[string]*types.Package) ([]Diagnostic, error) {
+	uses, err := castUses(nil, file, file.Messages, c.types)
+	if err != nil {
+		return nil, err
+	}
+	var diags []Diagnostic
+	for _, use := range uses {
+		severity, msg := checkCastType(use, pkgs)
+		if msg == "" {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Severity: severity,
+			Position: fieldPosition(use.Field),
+			Field:    use.Field.Desc.FullName(),
+			Message:  fmt.Sprintf("(%s) %q: %s", use.Option, use.CastType, msg),
+		})
+	}
+	return diags, nil
+}
+
+// checkCastType returns the problem with one cast type, or an empty message
+// if there is none.
+func checkCastType(use castUse, pkgs map[string]*types.Package) (Severity, string) {
+	var obj types.Object
+	if use.Ref.ImportPath == "" {
+		// Anything but a predeclared type is declared in the generated
+		// package, which does not exist yet.
+		if obj = types.Universe.Lookup(use.Ref.Name); obj == nil {
+			return Warning, ""
+		}
+	} else {
+		pkg, ok := pkgs[use.Ref.ImportPath]
+		if !ok {
+			return Warning, fmt.Sprintf("package %s is not available, so the type is not checked", use.Ref.ImportPath)
+		}
+		if obj = pkg.Scope().Lookup(use.Ref.Name); obj == nil {
+			return Error, fmt.Sprintf("package %s declares no %s", use.Ref.ImportPath, use.Ref.Name)
+		}
+		if !obj.Exported() {
+			return Error, fmt.Sprintf("%s is not exported by package %s", use.Ref.Name, use.Ref.ImportPath)
+		}
+	}
+	if _, ok := obj.(*types.TypeName); !ok {
+		return Error, fmt.Sprintf("%s is not a type", obj.Name())
+	}
+	if underlying := obj.Type().Underlying(); !types.Identical(underlying, use.GoType) {
+		return Error, fmt.Sprintf("underlying type is %s, want %s", underlying, use.GoType)
+	}
+	return Warning, ""
+}
+
+// castUses appends the cast types of the fields of messages, at any depth.
+// Casts that BuildPlan would reject, or that Lint reports, are left out.
+func castUses(uses []castUse, file *protogen.File, messages []*protogen.Message, known *optionTypes) ([]castUse, error) {
+	for _, message := range messages {
+		if message.Desc.IsMapEntry() {
+			continue
+		}
+		for _, field := range message.Fields {
+			options, err := fieldOptions(known, field)
+			if err != nil {
+				return nil, fieldError(field, err)
+			}
+			add := func(name protoreflect.Name, valueField *protogen.Field) {
+				castType := stringFieldOption(options, name)
+				if castType == "" || validateCastType(castType) != nil || isMessageKind(valueField.Desc.Kind()) {
+					return
+				}
+				ref := parseCastType(castType)
+				if protogen.GoImportPath(ref.ImportPath) == file.GoImportPath {
+					ref.ImportPath = ""
+				}
+				uses = append(uses, castUse{Field: field, Option: name, CastType: castType, Ref: ref, GoType: goType(valueField.Desc.Kind())})
+			}
+			if field.Desc.IsMap() {
+				add("cast_key_type", field.Message.Fields[0])
+				add("cast_value_type", field.Message.Fields[1])
+			} else {
+				add("cast_type", field)
+			}
+		}
+		var err error
+		if uses, err = castUses(uses, file, message.Messages, known); err != nil {
+			return nil, err
+		}
+	}
+	return uses, nil
+}
+
+// goType returns the Go type gengo generates for a single scalar, string,
+// bytes or enum value of kind, going by the underlying type of enums.
+func goType(kind protoreflect.Kind) types.Type {
+	switch kind {
+	case protoreflect.EnumKind:
+		return types.Typ[types.Int32]
+	case protoreflect.BytesKind:
+		return types.NewSlice(types.Universe.Lookup("byte").Type())
+	}
+	return types.Universe.Lookup(goScalarTypes[kind]).Type()
+}
@@ -0,0 +1,154 @@
+package cast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"runtime/debug"
+	"strings"
+
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const modulePath = "github.com/prysmaticlabs/protoc-gen-go-cast"
+
+// generatedLine matches the comment that marks a file as generated, as
+// described at https://golang.org/s/generatedcode.
+var generatedLine = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// Version returns the version of the protoc-gen-go-cast module the binary was
+// built from, which is (devel) or a pseudo-version for a build of the module
+// itself, or (unknown) when the binary records no module information, as
+// builds with rules_go do not.
+func Version() string {
+	return moduleVersion(modulePath)
+}
+
+// moduleVersion returns the version of the module with the given path
+// recorded in the build info of the binary.
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(unknown)"
+	}
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "(unknown)"
+}
+
+// castHeader returns the header of a casted file, which names the plugin
+// and the versions that generated it along with a summary of plan. versions
+// are the ones the header of the uncast file lists, such as the versions of
+// protoc-gen-go and protoc gengo writes.
+func castHeader(file *protogen.File, plan *Plan, versions []toolVersion) string {
+	var b strings.Builder
+	b.WriteString("// Code generated by protoc-gen-go-cast. DO NOT EDIT.\n")
+	if gengo.GenerateVersionMarkers {
+		versions = append([]toolVersion{{Name: "protoc-gen-go-cast", Version: Version()}}, versions...)
+		width := 0
+		for _, v := range versions {
+			if len(v.Name) > width {
+				width = len(v.Name)
+			}
+		}
+		b.WriteString("// versions:\n")
+		for _, v := range versions {
+			fmt.Fprintf(&b, "// \t%-*s %s\n", width, v.Name, v.Version)
+		}
+	}
+	if file.Proto.GetOptions().GetDeprecated() {
+		fmt.Fprintf(&b, "// %s is a deprecated file.\n", file.Desc.Path())
+	} else {
+		fmt.Fprintf(&b, "// source: %s\n", file.Desc.Path())
+	}
+	cast, tagged := plan.summary()
+	fmt.Fprintf(&b, "// summary: %s cast, %s tagged\n", pluralFields(cast), pluralFields(tagged))
+	return b.String()
+}
+
+// toolVersion is a line of the versions in a header, such as the version of
+// protoc.
+type toolVersion struct {
+	Name    string
+	Version string
+}
+
+// generatedHeader returns the comment group before the package clause of f
+// that starts by marking it as generated, or nil if there is none.
+func generatedHeader(f *ast.File) *ast.CommentGroup {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		if generatedLine.MatchString(group.List[0].Text) {
+			return group
+		}
+	}
+	return nil
+}
+
+// headerVersions returns the versions listed in a header the way gengo
+// lists them, one tool per line after a "versions:" line.
+func headerVersions(header *ast.CommentGroup) []toolVersion {
+	var versions []toolVersion
+	listed := false
+	for _, c := range header.List {
+		if c.Text == "// versions:" {
+			listed = true
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+		if !listed || !strings.HasPrefix(c.Text, "// \t") || len(fields) != 2 {
+			listed = false
+			continue
+		}
+		versions = append(versions, toolVersion{Name: fields[0], Version: fields[1]})
+	}
+	return versions
+}
+
+func pluralFields(n int) string {
+	if n == 1 {
+		return "1 field"
+	}
+	return fmt.Sprintf("%d fields", n)
+}
+
+// replaceHeader replaces the header that marks src as generated with header.
+// Comments gengo copies from the .proto file around it are kept.
+func replaceHeader(src []byte, header string) ([]byte, error) {
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.PackageClauseOnly)
+	if err != nil {
+		return nil, err
+	}
+	group := generatedHeader(astFile)
+	if group == nil {
+		return nil, errors.New("no generated code header")
+	}
+	start := fset.Position(group.Pos()).Offset
+	end := fset.Position(group.End()).Offset
+	if end < len(src) && src[end] == '\n' {
+		end++
+	}
+	var out bytes.Buffer
+	out.Write(src[:start])
+	out.WriteString(header)
+	out.Write(src[end:])
+	return out.Bytes(), nil
+}
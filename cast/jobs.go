@@ -0,0 +1,43 @@
+package cast
+
+import (
+	"sync"
+)
+
+// Apply replaces the .pb.go files of plans with their casted contents. Up to
+// Options.Jobs files are rendered at a time, while the calls that change the
+// plugin are made in the order of plans, one at a time, so the response is
+// the same for any number of jobs. The first error in that order is
+// returned.
+func (c *Caster) Apply(plans ...*Plan) error {
+	jobs := c.opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	casted := make([]*castedFile, len(plans))
+	errs := make([]error, len(plans))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for i := range plans {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			casted[i], errs[i] = renderCastedFile(c.gen, plans[i].file, plans[i], c.opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, plan := range plans {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if err := casted[i].replace(c.gen, plan.file, plan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cast
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Severity tells whether a Diagnostic points at an annotation that breaks
+// the generated code or at one that has no effect.
+type Severity int
+
+const (
+	// Warning is an annotation that is ignored.
+	Warning Severity = iota
+	// Error is an annotation that is invalid or generates Go code that does
+	// not compile.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a problem with the annotations of a field.
+type Diagnostic struct {
+	Severity Severity
+	// Position is the .proto file, line and column of the field. Only the
+	// file is given if the request has no source info.
+	Position string
+	Field    protoreflect.FullName
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: field %s: %s", d.Position, d.Severity, d.Field, d.Message)
+}
+
+// Lint checks the cast and tag annotations of the fields of file, at any
+// depth, against the kind and cardinality of the field. Diagnostics are
+// returned in the order the fields are declared.
+func (c *Caster) Lint(file *protogen.File) ([]Diagnostic, error) {
+	return lintMessages(nil, file.Messages, c.types)
+}
+
+func lintMessages(diags []Diagnostic, messages []*protogen.Message, types *optionTypes) ([]Diagnostic, error) {
+	for _, message := range messages {
+		if message.Desc.IsMapEntry() {
+			continue
+		}
+		for _, field := range message.Fields {
+			options, err := fieldOptions(types, field)
+			if err != nil {
+				return nil, fieldError(field, err)
+			}
+			diags = append(diags, lintField(field, options)...)
+		}
+		var err error
+		if diags, err = lintMessages(diags, message.Messages, types); err != nil {
+			return nil, err
+		}
+	}
+	return diags, nil
+}
+
+// lintField checks the options of one field.
+func lintField(field *protogen.Field, options []customOption) []Diagnostic {
+	var diags []Diagnostic
+	report := func(severity Severity, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Severity: severity,
+			Position: fieldPosition(field),
+			Field:    field.Desc.FullName(),
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Legacy cast options are also written as struct tags, so the cast
+	// checks run before the tag checks rather than instead of them.
+	lintCast := func(opt customOption) {
+		if opt.Desc.Kind() != protoreflect.StringKind || opt.Desc.IsList() {
+			report(Warning, "(%s) is not a string and is ignored", opt.Desc.FullName())
+			return
+		}
+		castType := opt.Value.String()
+		if err := validateCastType(castType); err != nil {
+			report(Error, "invalid (%s): %v", opt.Cast, err)
+			return
+		}
+		switch {
+		case opt.Cast == "cast_type" && field.Desc.IsMap():
+			report(Warning, "(cast_type) is ignored on map fields, which are cast with (cast_key_type) and (cast_value_type)")
+		case opt.Cast == "cast_type" && isMessageKind(field.Desc.Kind()):
+			report(Error, "(cast_type) %q on a %s field: only scalar, string, bytes and enum fields can be cast", castType, field.Desc.Kind())
+		case opt.Cast != "cast_type" && !field.Desc.IsMap():
+			report(Warning, "(%s) is ignored on fields that are not maps", opt.Cast)
+		case opt.Cast == "cast_value_type" && isMessageKind(field.Desc.MapValue().Kind()):
+			report(Error, "(cast_value_type) %q on a map of messages: only scalar, string, bytes and enum values can be cast", castType)
+		}
+	}
+
+	tagged := make(map[string]protoreflect.FullName)
+	for _, opt := range options {
+		if err := unmatchedOptionError(opt); err != nil {
+			report(Error, "%v", err)
+			continue
+		}
+		if opt.Cast != "" && opt.Cast != "cast_setters" {
+			lintCast(opt)
+		}
+		if opt.Tag == "" {
+			continue
+		}
+		if _, ok := optionString(opt); !ok {
+			report(Warning, "(%s) is not a scalar and is not written as a struct tag", opt.Desc.FullName())
+			continue
+		}
+		if prev, ok := tagged[opt.Tag]; ok {
+			report(Warning, "(%s) and (%s) are both written as struct tag %s", prev, opt.Desc.FullName(), opt.Tag)
+			continue
+		}
+		tagged[opt.Tag] = opt.Desc.FullName()
+	}
+	return diags
+}
+
+// isMessageKind reports whether gengo generates a pointer to a message
+// struct for a value of kind.
+func isMessageKind(kind protoreflect.Kind) bool {
+	return kind == protoreflect.MessageKind || kind == protoreflect.GroupKind
+}
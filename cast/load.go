@@ -0,0 +1,134 @@
+package cast
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// castHeaderPrefix starts the header of a casted file.
+const castHeaderPrefix = "// Code generated by protoc-gen-go-cast."
+
+// reservedImportPath prefixes the import paths under which package names are
+// reserved in a generated file, such as the names of the imports of a loaded
+// file. The imports protogen adds for them are never used.
+const reservedImportPath = "protoc-gen-go-cast.invalid/reserved/"
+
+// LoadPlan plans the rewrite of content, a .pb.go file protoc-gen-go or a
+// compatible generator generated for file, as BuildPlan does for a file
+// generated by the plugin. content must start with a standard
+// "// Code generated ... DO NOT EDIT." header, whose versions are kept in the
+// casted file. Files that are already cast are rejected, as casting them
+// again would repeat their struct tags.
+func (c *Caster) LoadPlan(file *protogen.File, content []byte) (*Plan, error) {
+	if bytes.Contains(content, []byte(castHeaderPrefix)) {
+		return nil, errors.New("already cast")
+	}
+	astFile, err := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if generatedHeader(astFile) == nil {
+		return nil, errors.New("no // Code generated ... DO NOT EDIT. header")
+	}
+
+	g := c.gen.NewGeneratedFile(file.GeneratedFilenamePrefix+".pb.go", file.GoImportPath)
+	if _, err := g.Write(content); err != nil {
+		return nil, err
+	}
+	// protogen names the packages of cast types without looking at content.
+	// Reserve the names content imports under, so they are not reused.
+	var reserved []string
+	for _, spec := range astFile.Imports {
+		name := importName(spec)
+		if name == "" {
+			continue
+		}
+		reserved = append(reserved, reserveName(g, name))
+	}
+	plan, err := buildPlan(g, file, c.types, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range reserved {
+		plan.reserved[name] = true
+	}
+	plan.loaded = true
+	return plan, nil
+}
+
+// dropReservedImports removes the imports protogen added for the names in
+// reserved. protogen adds its import declaration before any other, so only
+// the first one is searched.
+func dropReservedImports(fset *token.FileSet, f *ast.File, reserved map[string]bool) error {
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range append([]ast.Spec(nil), decl.Specs...) {
+			spec := spec.(*ast.ImportSpec)
+			if spec.Name == nil || !reserved[spec.Name.Name] {
+				continue
+			}
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return err
+			}
+			astutil.DeleteNamedImport(fset, f, spec.Name.Name, path)
+		}
+		break
+	}
+	return nil
+}
+
+// mergeImports moves the imports protogen added to a loaded file into the
+// imports of the file itself. protogen adds its import declaration before
+// any other, and dropReservedImports has removed the names the file uses
+// from it.
+func mergeImports(fset *token.FileSet, f *ast.File) error {
+	var decls []*ast.GenDecl
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			decls = append(decls, decl)
+		}
+	}
+	if len(decls) < 2 {
+		return nil
+	}
+	added := decls[0]
+	f.Decls = f.Decls[1:]
+	f.Imports = f.Imports[len(added.Specs):]
+	for _, spec := range added.Specs {
+		spec := spec.(*ast.ImportSpec)
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+		astutil.AddNamedImport(fset, f, spec.Name.Name, path)
+	}
+	return nil
+}
+
+// importName returns the name a file refers to an import by, or an empty
+// string for blank and dot imports. Imports without a name are assumed to be
+// named as goimports assumes.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		if spec.Name.Name == "_" || spec.Name.Name == "." {
+			return ""
+		}
+		return spec.Name.Name
+	}
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return importPathName(importPath)
+}
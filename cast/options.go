@@ -0,0 +1,211 @@
+package cast
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast/castpb"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	fieldOptionsName   protoreflect.FullName = "google.protobuf.FieldOptions"
+	messageOptionsName protoreflect.FullName = "google.protobuf.MessageOptions"
+)
+
+// castOptions maps the options shipped in cast/options.proto to their short
+// names, which is how the rest of the plugin refers to them.
+var castOptions = map[protoreflect.FullName]protoreflect.Name{
+	castpb.E_CastType.TypeDescriptor().FullName():      "cast_type",
+	castpb.E_CastKeyType.TypeDescriptor().FullName():   "cast_key_type",
+	castpb.E_CastValueType.TypeDescriptor().FullName(): "cast_value_type",
+	castpb.E_CastSetters.TypeDescriptor().FullName():   "cast_setters",
+}
+
+// customOption is a single custom option set on a field or message, decoded
+// with the type of the extension that declares it.
+type customOption struct {
+	Desc  protoreflect.ExtensionDescriptor
+	Value protoreflect.Value
+	// Cast is the short name of the cast option this is, or empty if the
+	// option does not configure casting.
+	Cast protoreflect.Name
+	// Tag is the struct tag key the option is written as, or empty for
+	// cast options.
+	Tag string
+	// Unmatched is the short name of the cast option an extension declared
+	// outside of cast/options.proto is named like, when such extensions are
+	// not treated as cast options. It is empty otherwise.
+	Unmatched protoreflect.Name
+}
+
+// optionTypes indexes the custom options declared in a request. It is built
+// once per run, and the options of a field or message are decoded the first
+// time they are asked for.
+type optionTypes struct {
+	types *protoregistry.Types
+	cast  map[protoreflect.FullName]protoreflect.Name
+	tags  map[protoreflect.FullName]string
+	// unmatched holds the extensions named like a cast option that are
+	// neither cast options nor struct tags.
+	unmatched map[protoreflect.FullName]protoreflect.Name
+	// decoded holds the options of each field and message by full name,
+	// and unknown the options decoded from each run of unknown fields, which
+	// many fields share.
+	decoded map[protoreflect.FullName][]customOption
+	unknown map[string][]customOption
+}
+
+// extensionTypes builds a local registry of every FieldOptions and
+// MessageOptions extension in the request. The descriptors handed to the
+// plugin are parsed without these extensions being known, so their values
+// only exist as unknown fields.
+//
+// Cast options are matched by their full name in cast/options.proto. With
+// legacyNames, any other extension named like one of them, such as a locally
+// declared cast_type, is treated as that option too. Without it, such an
+// extension is recorded as unmatched, so setting it is an error rather than
+// a struct tag.
+func extensionTypes(allExtensions []*protogen.Extension, legacyNames bool) (*optionTypes, error) {
+	types := &optionTypes{
+		types:     new(protoregistry.Types),
+		cast:      make(map[protoreflect.FullName]protoreflect.Name),
+		tags:      make(map[protoreflect.FullName]string),
+		unmatched: make(map[protoreflect.FullName]protoreflect.Name),
+		decoded:   make(map[protoreflect.FullName][]customOption),
+		unknown:   make(map[string][]customOption),
+	}
+	legacy := make(map[protoreflect.Name]bool)
+	for _, name := range castOptions {
+		legacy[name] = legacyNames
+	}
+	for _, ee := range allExtensions {
+		switch ee.Desc.ContainingMessage().FullName() {
+		case fieldOptionsName, messageOptionsName:
+		default:
+			continue
+		}
+		if err := types.types.RegisterExtension(dynamicpb.NewExtensionType(ee.Desc)); err != nil {
+			return nil, fmt.Errorf("registering extension %s: %v", ee.Desc.FullName(), err)
+		}
+		if name, ok := castOptions[ee.Desc.FullName()]; ok {
+			types.cast[ee.Desc.FullName()] = name
+			continue
+		}
+		if isLegacy, ok := legacy[ee.Desc.Name()]; ok && !isLegacy {
+			// Writing it as a struct tag would drop its casts silently.
+			types.unmatched[ee.Desc.FullName()] = ee.Desc.Name()
+			continue
+		}
+		if legacy[ee.Desc.Name()] {
+			types.cast[ee.Desc.FullName()] = ee.Desc.Name()
+		}
+		types.tags[ee.Desc.FullName()] = snakeToCamel(string(ee.Desc.Name()))
+	}
+	return types, nil
+}
+
+// fieldOptions decodes the options of a field against types and returns
+// every extension set on it, ordered by field number.
+func fieldOptions(types *optionTypes, field *protogen.Field) ([]customOption, error) {
+	options, ok := field.Desc.Options().(*descriptorpb.FieldOptions)
+	if !ok || options == nil {
+		return nil, nil
+	}
+	return decodeOptions(types, field.Desc.FullName(), options)
+}
+
+// messageOptions decodes the options of a message against types and
+// returns every extension set on it, ordered by field number.
+func messageOptions(types *optionTypes, message *protogen.Message) ([]customOption, error) {
+	options, ok := message.Desc.Options().(*descriptorpb.MessageOptions)
+	if !ok || options == nil {
+		return nil, nil
+	}
+	return decodeOptions(types, message.Desc.FullName(), options)
+}
+
+// decodeOptions returns the extensions set in the options of the descriptor
+// with the given name, decoding them on the first call. Options without
+// unknown fields, as on most fields, are not decoded at all, and the same
+// unknown fields are decoded only once.
+func decodeOptions(types *optionTypes, name protoreflect.FullName, options proto.Message) ([]customOption, error) {
+	if opts, ok := types.decoded[name]; ok {
+		return opts, nil
+	}
+
+	var opts []customOption
+	m := options.ProtoReflect()
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsExtension() {
+			opts = append(opts, types.option(fd, v))
+		}
+		return true
+	})
+	if unknown := m.GetUnknown(); len(unknown) > 0 {
+		decoded, ok := types.unknown[string(unknown)]
+		if !ok {
+			msg := m.Type().New()
+			if err := (proto.UnmarshalOptions{Resolver: types.types}).Unmarshal(unknown, msg.Interface()); err != nil {
+				return nil, fmt.Errorf("decoding options of %s: %v", name, err)
+			}
+			msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+				if fd.IsExtension() {
+					decoded = append(decoded, types.option(fd, v))
+				}
+				return true
+			})
+			types.unknown[string(unknown)] = decoded
+		}
+		opts = append(opts, decoded...)
+	}
+	sort.Slice(opts, func(i, j int) bool {
+		return opts[i].Desc.Number() < opts[j].Desc.Number()
+	})
+	types.decoded[name] = opts
+	return opts, nil
+}
+
+func (types *optionTypes) option(xd protoreflect.ExtensionDescriptor, v protoreflect.Value) customOption {
+	return customOption{
+		Desc:      xd,
+		Value:     v,
+		Cast:      types.cast[xd.FullName()],
+		Tag:       types.tags[xd.FullName()],
+		Unmatched: types.unmatched[xd.FullName()],
+	}
+}
+
+// unmatchedOptionError returns the error for setting an option that is named
+// like a cast option but is not one, or nil if opt is not such an option.
+func unmatchedOptionError(opt customOption) error {
+	if opt.Unmatched == "" {
+		return nil
+	}
+	return fmt.Errorf("(%s) is not the (%s) option of cast/options.proto and is ignored: import cast/options.proto and set (cast.v1.%s), or set legacy_option_names=true", opt.Desc.FullName(), opt.Unmatched, opt.Unmatched)
+}
+
+// optionString formats a scalar option value the way it is written in the
+// .proto file. It reports false for lists and message values.
+func optionString(opt customOption) (string, bool) {
+	if opt.Desc.IsList() || opt.Desc.IsMap() {
+		return "", false
+	}
+	switch opt.Desc.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "", false
+	case protoreflect.EnumKind:
+		if ev := opt.Desc.Enum().Values().ByNumber(opt.Value.Enum()); ev != nil {
+			return string(ev.Name()), true
+		}
+		return fmt.Sprint(int32(opt.Value.Enum())), true
+	case protoreflect.BytesKind:
+		return string(opt.Value.Bytes()), true
+	}
+	return fmt.Sprint(opt.Value.Interface()), true
+}
@@ -0,0 +1,337 @@
+package cast
+
+import (
+	"fmt"
+	"go/ast"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Plan lists the rewrites of one generated .pb.go file. Everything is keyed
+// by the Go identifiers protogen assigns to messages and fields, so the AST
+// pass only touches declarations gengo generated for them.
+type Plan struct {
+	// file is the file the plan is for.
+	file *protogen.File
+	// gennedFile is the .pb.go file gengo generated. Generators enabled with
+	// the plugins parameter may add to it before it is rewritten.
+	gennedFile *protogen.GeneratedFile
+	// loaded is set when gennedFile holds a .pb.go file given to LoadPlan
+	// rather than one the plugin generated.
+	loaded bool
+	// fields holds the rewrites of struct fields, and order their keys in
+	// the order they were planned.
+	fields map[fieldKey]*fieldRewrite
+	order  []fieldKey
+	// getters holds the rewrites of generated GetX methods.
+	getters map[methodKey]*fieldRewrite
+	// insertions holds methods generated right after a getter.
+	insertions map[methodKey]string
+	// imports lists the cast type imports with an explicit alias or a name
+	// protogen would not give them, in the order they appear. Other imports
+	// are added through the generated file.
+	imports []aliasedImport
+	// reserved holds the names reserved in gennedFile under import paths
+	// below reservedImportPath, whose imports are left out of the casted
+	// file. reservedLocals is set once the names of gengoLocals are among
+	// them.
+	reserved       map[string]bool
+	reservedLocals bool
+	// goImportPath is the import path of the generated package.
+	goImportPath protogen.GoImportPath
+}
+
+// aliasedImport is an import of a cast type package under the alias the
+// field that uses it asks for.
+type aliasedImport struct {
+	Name  string
+	Path  string
+	Field *protogen.Field
+}
+
+// fieldKey identifies a field of a generated struct, such as the Epoch field
+// of ListAttestationsRequest_Epoch.
+type fieldKey struct {
+	Struct string
+	Field  string
+}
+
+// methodKey identifies a method of a generated type, such as GetEpoch on
+// *ListAttestationsRequest.
+type methodKey struct {
+	Receiver string
+	Method   string
+}
+
+func (k methodKey) String() string {
+	return fmt.Sprintf("(*%s).%s", k.Receiver, k.Method)
+}
+
+// fieldRewrite describes how a field and its getter change.
+type fieldRewrite struct {
+	Field *protogen.Field
+	// CastType replaces the element type of the struct field and the result
+	// of the getter when set. Repeated and Pointer tell how gengo wraps the
+	// element in the struct field.
+	CastType *typeName
+	Repeated bool
+	Pointer  bool
+	// MapType replaces the key or value type of a map field and its getter.
+	MapType *mapCastType
+	// Tags are appended to the struct tag.
+	Tags string
+}
+
+// File returns the file the plan is for.
+func (p *Plan) File() *protogen.File {
+	return p.file
+}
+
+// GeneratedFile returns the .pb.go file protoc-gen-go generated, which the
+// plan rewrites. Code added to it before the plan is applied ends up in the
+// casted file.
+func (p *Plan) GeneratedFile() *protogen.GeneratedFile {
+	return p.gennedFile
+}
+
+// buildPlan collects the rewrites for the messages of file, at any depth.
+// Two fields that would rewrite the same declaration are reported as an error.
+func buildPlan(g *protogen.GeneratedFile, file *protogen.File, types *optionTypes, opts Options) (*Plan, error) {
+	plan := &Plan{
+		file:         file,
+		gennedFile:   g,
+		fields:       make(map[fieldKey]*fieldRewrite),
+		getters:      make(map[methodKey]*fieldRewrite),
+		insertions:   make(map[methodKey]string),
+		reserved:     make(map[string]bool),
+		goImportPath: file.GoImportPath,
+	}
+	if err := plan.addMessages(g, file.Messages, types, opts); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (p *Plan) addMessages(g *protogen.GeneratedFile, messages []*protogen.Message, types *optionTypes, opts Options) error {
+	for _, message := range messages {
+		if message.Desc.IsMapEntry() {
+			continue
+		}
+		msgOptions, err := messageOptions(types, message)
+		if err != nil {
+			return fmt.Errorf("%s: message %s: %v", message.Location.SourceFile, message.Desc.FullName(), err)
+		}
+		for _, opt := range msgOptions {
+			if err := unmatchedOptionError(opt); err != nil {
+				return fmt.Errorf("%s: message %s: %v", message.Location.SourceFile, message.Desc.FullName(), err)
+			}
+		}
+		setters := opts.Setters || boolOption(msgOptions, "cast_setters")
+		for _, field := range message.Fields {
+			options, err := fieldOptions(types, field)
+			if err != nil {
+				return fieldError(field, err)
+			}
+			if err := p.addField(g, message, field, options, setters); err != nil {
+				return err
+			}
+		}
+		if err := p.addMessages(g, message.Messages, types, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Plan) addField(g *protogen.GeneratedFile, message *protogen.Message, field *protogen.Field, options []customOption, setters bool) error {
+	for _, opt := range options {
+		if err := unmatchedOptionError(opt); err != nil {
+			return fieldError(field, err)
+		}
+	}
+	for _, name := range []protoreflect.Name{"cast_type", "cast_key_type", "cast_value_type"} {
+		castType := stringFieldOption(options, name)
+		if err := validateCastType(castType); err != nil {
+			return fieldError(field, fmt.Errorf("invalid (%s): %v", name, err))
+		}
+	}
+
+	rewrite := &fieldRewrite{Field: field, Tags: structTagsFromField(options)}
+	if field.Desc.IsMap() {
+		keyCastType := stringFieldOption(options, "cast_key_type")
+		valueCastType := stringFieldOption(options, "cast_value_type")
+		if keyCastType != "" || valueCastType != "" {
+			rewrite.MapType = &mapCastType{}
+			if keyCastType != "" {
+				rewrite.MapType.key = p.castType(g, field, keyCastType)
+			}
+			if valueCastType != "" {
+				rewrite.MapType.value = p.castType(g, field, valueCastType)
+			}
+		}
+	} else if castType := castTypeFromField(options); castType != "" {
+		rewrite.CastType = p.castType(g, field, castType)
+		if field.Desc.IsList() {
+			rewrite.Repeated = true
+		} else {
+			rewrite.Pointer = isPointerField(field)
+		}
+	}
+	if rewrite.CastType == nil && rewrite.MapType == nil && rewrite.Tags == "" {
+		return nil
+	}
+
+	// Oneof members live in a wrapper struct, e.g. ListAttestationsRequest_Epoch,
+	// while their getter is declared on the parent and unwraps them with a type
+	// assertion.
+	structKey := fieldKey{Struct: message.GoIdent.GoName, Field: field.GoName}
+	if field.Oneof != nil && !field.Oneof.Desc.IsSynthetic() {
+		structKey.Struct = field.GoIdent.GoName
+	}
+	if prev, ok := p.fields[structKey]; ok {
+		return fieldError(field, fmt.Errorf("generates struct field %s.%s, as does %s", structKey.Struct, structKey.Field, prev.Field.Desc.FullName()))
+	}
+	p.fields[structKey] = rewrite
+	p.order = append(p.order, structKey)
+	if rewrite.CastType == nil && rewrite.MapType == nil {
+		return nil
+	}
+
+	getter := methodKey{Receiver: message.GoIdent.GoName, Method: "Get" + field.GoName}
+	if prev, ok := p.getters[getter]; ok {
+		return fieldError(field, fmt.Errorf("generates getter %s, as does %s", getter, prev.Field.Desc.FullName()))
+	}
+	p.getters[getter] = rewrite
+	if isPointerField(field) && !hasFieldNamed(message, "Has"+field.GoName, "Clear"+field.GoName) {
+		p.insertions[getter] += presenceMethods(getter.Receiver, field)
+	}
+	if setters && !hasFieldNamed(message, "Set"+field.GoName) {
+		if setType := castSetterType(g, field, rewrite); setType != "" {
+			p.insertions[getter] += setterMethod(getter.Receiver, field, setType)
+		}
+	}
+	return nil
+}
+
+// summary returns the number of fields whose type is cast and the number of
+// fields that get struct tags.
+func (p *Plan) summary() (cast, tagged int) {
+	for _, rewrite := range p.fields {
+		if rewrite.CastType != nil || rewrite.MapType != nil {
+			cast++
+		}
+		if rewrite.Tags != "" {
+			tagged++
+		}
+	}
+	return cast, tagged
+}
+
+// gengoLocals are the identifiers gengo declares in the functions it
+// generates, such as the receiver x of every method. A package named like one
+// of them would be shadowed where it is used in a getter or setter.
+var gengoLocals = []string{"b", "i", "mi", "ms", "out", "p", "v", "x"}
+
+// castType resolves a cast type to the name it is referred to by in g. Its
+// package is named the way goimports assumes it is, after the last element
+// of the import path without a major version suffix or a go- prefix, adding
+// a number on collision with another import or with gengoLocals. A package
+// protogen would name the same way is imported through g, which reuses an
+// import the file already has. Explicit aliases are imported as written.
+func (p *Plan) castType(g *protogen.GeneratedFile, field *protogen.Field, castType string) *typeName {
+	ref := parseCastType(castType)
+	if ref.ImportPath == "" || protogen.GoImportPath(ref.ImportPath) == p.goImportPath {
+		return &typeName{Name: ref.Name}
+	}
+	if ref.Alias != "" {
+		p.addImport(aliasedImport{Name: ref.Alias, Path: ref.ImportPath, Field: field})
+		return &typeName{Package: ref.Alias, Name: ref.Name, ImportPath: ref.ImportPath}
+	}
+	if !p.reservedLocals {
+		for _, name := range gengoLocals {
+			p.reserved[reserveName(g, name)] = true
+		}
+		p.reservedLocals = true
+	}
+	if name := importPathName(ref.ImportPath); name != "" && name != path.Base(ref.ImportPath) {
+		// The name is reserved under a path of its own, as protogen would
+		// name the package after the last element of its path.
+		name = reserveName(g, ref.ImportPath+"/"+name)
+		p.reserved[name] = true
+		p.addImport(aliasedImport{Name: name, Path: ref.ImportPath, Field: field})
+		return &typeName{Package: name, Name: ref.Name, ImportPath: ref.ImportPath}
+	}
+	qualified := g.QualifiedGoIdent(protogen.GoIdent{GoName: ref.Name, GoImportPath: protogen.GoImportPath(ref.ImportPath)})
+	return &typeName{Package: strings.TrimSuffix(qualified, "."+ref.Name), Name: ref.Name, ImportPath: ref.ImportPath}
+}
+
+// reserveName makes g use a package name for the import path below
+// reservedImportPath that ends in name, and returns the package name, which
+// has a number added if name is already used.
+func reserveName(g *protogen.GeneratedFile, name string) string {
+	qualified := g.QualifiedGoIdent(protogen.GoIdent{GoImportPath: protogen.GoImportPath(reservedImportPath + name)})
+	return strings.TrimSuffix(qualified, ".")
+}
+
+// importPathName returns the package name goimports assumes from an import
+// path, such as bitfield for github.com/prysmaticlabs/go-bitfield and yaml
+// for gopkg.in/yaml.v2, or an empty string if it assumes none.
+func importPathName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			if dir := path.Dir(importPath); dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// addImport adds imp to the imports of the plan unless it is already there.
+func (p *Plan) addImport(imp aliasedImport) {
+	for _, prev := range p.imports {
+		if prev.Name == imp.Name && prev.Path == imp.Path {
+			return
+		}
+	}
+	p.imports = append(p.imports, imp)
+}
+
+// structType returns the type of the cast struct field.
+func (r *fieldRewrite) structType() ast.Expr {
+	switch {
+	case r.Repeated:
+		return &ast.ArrayType{Elt: r.CastType.expr()}
+	case r.Pointer:
+		return &ast.StarExpr{X: r.CastType.expr()}
+	}
+	return r.CastType.expr()
+}
+
+// getterType returns the result type of the cast getter, which dereferences
+// optional fields.
+func (r *fieldRewrite) getterType() ast.Expr {
+	if r.Repeated {
+		return &ast.ArrayType{Elt: r.CastType.expr()}
+	}
+	return r.CastType.expr()
+}
+
+// castDefault converts the value the getter returns for unset fields, such
+// as the zero value or the declared default of a proto2 field, to the cast
+// type.
+func (r *fieldRewrite) castDefault(value ast.Expr) ast.Expr {
+	return &ast.CallExpr{Fun: r.getterType(), Args: []ast.Expr{value}}
+}
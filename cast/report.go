@@ -0,0 +1,136 @@
+package cast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// planReport is the JSON form of a Plan.
+type planReport struct {
+	Source       string        `json:"source"`
+	GoImportPath string        `json:"go_import_path"`
+	Fields       []fieldReport `json:"fields"`
+}
+
+// fieldReport describes the rewrite of one struct field. Go types are
+// qualified by the import path of their package rather than the name it is
+// imported as, unless they are local or predeclared.
+type fieldReport struct {
+	Field    protoreflect.FullName `json:"field"`
+	Location string                `json:"location"`
+	GoField  string                `json:"go_field"`
+	GoType   string                `json:"go_type"`
+	CastType string                `json:"cast_type,omitempty"`
+	Imports  []importReport        `json:"imports,omitempty"`
+	Tags     string                `json:"tags,omitempty"`
+}
+
+// importReport is an import a cast type adds to the generated file.
+type importReport struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Report describes the rewrites of plan as JSON: for every field that is
+// cast or tagged, the Go type gengo generates, the type it is cast to, the
+// imports that adds, the struct tags it gets and its position in the .proto
+// file. Fields are listed in the order they are declared, with the fields of
+// nested messages after those of their parent.
+func (c *Caster) Report(plan *Plan) ([]byte, error) {
+	report := planReport{
+		Source:       plan.file.Desc.Path(),
+		GoImportPath: string(plan.goImportPath),
+		Fields:       []fieldReport{},
+	}
+	for _, key := range plan.order {
+		rewrite := plan.fields[key]
+		field := rewrite.Field
+		fr := fieldReport{
+			Field:    field.Desc.FullName(),
+			Location: fieldPosition(field),
+			GoField:  key.Struct + "." + key.Field,
+			GoType:   reportGoType(plan, field),
+		}
+		if len(rewrite.Tags) > 0 {
+			fr.Tags = rewrite.Tags[1:]
+		}
+		var castTypes []*typeName
+		switch {
+		case rewrite.CastType != nil:
+			castTypes = []*typeName{rewrite.CastType}
+			fr.CastType = rewrite.CastType.qualified()
+			switch {
+			case rewrite.Repeated:
+				fr.CastType = "[]" + fr.CastType
+			case rewrite.Pointer:
+				fr.CastType = "*" + fr.CastType
+			}
+		case rewrite.MapType != nil:
+			keyType := reportValueType(plan, field.Message.Fields[0])
+			if rewrite.MapType.key != nil {
+				castTypes = append(castTypes, rewrite.MapType.key)
+				keyType = rewrite.MapType.key.qualified()
+			}
+			valueType := reportValueType(plan, field.Message.Fields[1])
+			if rewrite.MapType.value != nil {
+				castTypes = append(castTypes, rewrite.MapType.value)
+				valueType = rewrite.MapType.value.qualified()
+			}
+			fr.CastType = fmt.Sprintf("map[%s]%s", keyType, valueType)
+		}
+		for _, t := range castTypes {
+			if t.Package == "" {
+				continue
+			}
+			imp := importReport{Name: t.Package, Path: t.ImportPath}
+			if c.opts.ImportRewriteFunc != nil {
+				imp.Path = string(c.opts.ImportRewriteFunc(protogen.GoImportPath(imp.Path)))
+			}
+			// The key and value of a map may share an import.
+			if len(fr.Imports) == 0 || fr.Imports[0] != imp {
+				fr.Imports = append(fr.Imports, imp)
+			}
+		}
+		report.Fields = append(report.Fields, fr)
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", plan.file.Desc.Path(), err)
+	}
+	return append(b, '\n'), nil
+}
+
+// reportGoType returns the type gengo generates for the struct field of
+// field.
+func reportGoType(plan *Plan, field *protogen.Field) string {
+	switch {
+	case field.Desc.IsMap():
+		return fmt.Sprintf("map[%s]%s", reportValueType(plan, field.Message.Fields[0]), reportValueType(plan, field.Message.Fields[1]))
+	case field.Desc.IsList():
+		return "[]" + reportValueType(plan, field)
+	case isPointerField(field):
+		return "*" + reportValueType(plan, field)
+	}
+	return reportValueType(plan, field)
+}
+
+// reportValueType returns the type gengo generates for a single value of
+// field.
+func reportValueType(plan *Plan, field *protogen.Field) string {
+	ident := func(ident protogen.GoIdent) string {
+		if ident.GoImportPath == plan.goImportPath {
+			return ident.GoName
+		}
+		return string(ident.GoImportPath) + "." + ident.GoName
+	}
+	switch field.Desc.Kind() {
+	case protoreflect.EnumKind:
+		return ident(field.Enum.GoIdent)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "*" + ident(field.Message.GoIdent)
+	}
+	return goScalarTypes[field.Desc.Kind()]
+}
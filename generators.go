@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
+	"github.com/prysmaticlabs/protoc-gen-go-cast/gengogrpc"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// generator adds code for the files protoc asks the plugin to generate. It
+// is enabled by listing its name in the plugins parameter.
+//
+// To add a generator, register it from the init function of its own file.
+type generator interface {
+	// Name is what the plugins parameter enables the generator by.
+	Name() string
+	// Params registers the plugin parameters of the generator. They are
+	// accepted whether or not the generator is enabled.
+	Params(flags *flag.FlagSet)
+	// Generate is called for every file to generate once protoc-gen-go has
+	// generated its .pb.go file and the cast rewrite of it is planned. Code
+	// written to plan.GeneratedFile() ends up in the casted file; the
+	// generator may also create files of its own.
+	Generate(gen *protogen.Plugin, file *protogen.File, plan *cast.Plan) error
+}
+
+// generators holds the registered generators by name.
+var generators = make(map[string]generator)
+
+// registerGenerator makes g available to the plugins parameter. It panics if
+// a generator with the same name is already registered.
+func registerGenerator(g generator) {
+	if _, ok := generators[g.Name()]; ok {
+		panic(fmt.Sprintf("generator %q registered twice", g.Name()))
+	}
+	generators[g.Name()] = g
+}
+
+// generatorNames returns the names of the registered generators, sorted.
+func generatorNames() []string {
+	names := make([]string, 0, len(generators))
+	for name := range generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enabledGenerators looks up the generators named in a list, in the order
+// given. protoc splits plugin parameters on commas, so names are separated by
+// plus signs, as in plugins=grpc+foo.
+func enabledGenerators(plugins string) ([]generator, error) {
+	var enabled []generator
+	seen := make(map[string]bool)
+	for _, name := range strings.FieldsFunc(plugins, func(r rune) bool { return r == '+' }) {
+		if seen[name] {
+			continue
+		}
+		g, ok := generators[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown plugin %q (supported: %s)", name, strings.Join(generatorNames(), ", "))
+		}
+		seen[name] = true
+		enabled = append(enabled, g)
+	}
+	return enabled, nil
+}
+
+func init() {
+	registerGenerator(grpcGenerator{})
+}
+
+// grpcGenerator adds gRPC service definitions to the .pb.go file.
+type grpcGenerator struct{}
+
+func (grpcGenerator) Name() string { return "grpc" }
+
+func (grpcGenerator) Params(*flag.FlagSet) {}
+
+func (grpcGenerator) Generate(gen *protogen.Plugin, file *protogen.File, plan *cast.Plan) error {
+	gengogrpc.GenerateFileContent(gen, file, plan.GeneratedFile())
+	return nil
+}
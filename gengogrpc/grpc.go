@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Package gengogrpc contains the gRPC code generator.
-package main
+package gengogrpc
 
 import (
 	"fmt"
@@ -394,4 +394,4 @@ func genServerMethod(gen *protogen.Plugin, file *protogen.File, g *protogen.Gene
 
 const deprecationComment = "// Deprecated: Do not use."
 
-func unexport(s string) string { return strings.ToLower(s[:1]) + s[1:] }
\ No newline at end of file
+func unexport(s string) string { return strings.ToLower(s[:1]) + s[1:] }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// importRules rewrites the import paths of generated files, including the
+// packages of cast types. An excluded path is kept as is. Otherwise the first
+// rewrite that matches replaces it, and the prefix is prepended to paths no
+// rewrite matches.
+type importRules struct {
+	Prefix   string
+	Excludes []string
+	Rewrites []importRewrite
+}
+
+// importRewrite replaces the matches of Pattern in an import path with
+// Replacement, which may refer to submatches as in regexp.Expand.
+type importRewrite struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// defaultImportExcludes are never prefixed, as in earlier versions.
+var defaultImportExcludes = []string{"context", "fmt", "math"}
+
+// importRuleNames lists the parameters that configure import rules. A rules
+// file holds one of them per line.
+var importRuleNames = []string{"import_prefix", "import_exclude", "import_map", "import_regexp"}
+
+func newImportRules() *importRules {
+	return &importRules{Excludes: append([]string(nil), defaultImportExcludes...)}
+}
+
+// rewrite applies the rules to an import path.
+func (r *importRules) rewrite(importPath protogen.GoImportPath) protogen.GoImportPath {
+	path := string(importPath)
+	for _, exclude := range r.Excludes {
+		if path == exclude || strings.HasPrefix(path, exclude+"/") {
+			return importPath
+		}
+	}
+	for _, rw := range r.Rewrites {
+		if rw.Pattern.MatchString(path) {
+			return protogen.GoImportPath(rw.Pattern.ReplaceAllString(path, rw.Replacement))
+		}
+	}
+	return protogen.GoImportPath(r.Prefix + path)
+}
+
+// set adds the rule given by a parameter:
+//
+//	import_prefix=PREFIX       prepend PREFIX to paths no other rule matches
+//	import_exclude=PATH        keep PATH and the paths below it as they are
+//	import_map=FROM=TO         replace the leading FROM of a path with TO
+//	import_regexp=REGEXP=REPL  replace the matches of REGEXP with REPL
+func (r *importRules) set(name, value string) error {
+	switch name {
+	case "import_prefix":
+		r.Prefix = value
+	case "import_exclude":
+		if value == "" {
+			return fmt.Errorf("%s: empty import path", name)
+		}
+		r.Excludes = append(r.Excludes, value)
+	case "import_map":
+		i := strings.Index(value, "=")
+		if i <= 0 {
+			return fmt.Errorf("%s=%s: want FROM=TO", name, value)
+		}
+		from, to := value[:i], value[i+1:]
+		r.Rewrites = append(r.Rewrites, importRewrite{
+			Pattern:     regexp.MustCompile("^" + regexp.QuoteMeta(from) + "(/|$)"),
+			Replacement: strings.ReplaceAll(to, "$", "$$") + "${1}",
+		})
+	case "import_regexp":
+		i := strings.Index(value, "=")
+		if i <= 0 {
+			return fmt.Errorf("%s=%s: want REGEXP=REPLACEMENT", name, value)
+		}
+		pattern, err := regexp.Compile(value[:i])
+		if err != nil {
+			return fmt.Errorf("%s=%s: %v", name, value, err)
+		}
+		r.Rewrites = append(r.Rewrites, importRewrite{Pattern: pattern, Replacement: value[i+1:]})
+	default:
+		return fmt.Errorf("unknown import rule %q (supported: %s)", name, strings.Join(importRuleNames, ", "))
+	}
+	return nil
+}
+
+// load adds the rules of a file with one NAME=VALUE rule per line, written
+// like the parameter of the same name. Blank lines and lines starting with #
+// are skipped.
+func (r *importRules) load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := strings.Index(text, "=")
+		if i < 0 {
+			return fmt.Errorf("%s:%d: want NAME=VALUE", filename, line)
+		}
+		if err := r.set(text[:i], text[i+1:]); err != nil {
+			return fmt.Errorf("%s:%d: %v", filename, line, err)
+		}
+	}
+	return s.Err()
+}
+
+// importRuleFlag sets one kind of import rule from a plugin parameter, which
+// may be repeated.
+type importRuleFlag struct {
+	rules *importRules
+	name  string
+}
+
+func (f importRuleFlag) String() string { return "" }
+
+func (f importRuleFlag) Set(value string) error { return f.rules.set(f.name, value) }
@@ -0,0 +1,190 @@
+// Package casttest builds the requests protoc hands to protoc-gen-go-cast, for
+// the tests of the plugin and of the cast package.
+package casttest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast/castpb"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// Field numbers of the options File declares and of those in
+// cast/options.proto.
+const (
+	SSZSizeNumber        = 50000
+	SSZMaxNumber         = 50001
+	SpecNameNumber       = 50002
+	LegacyCastTypeNumber = 50003
+	CastTypeNumber       = 50600
+	CastKeyTypeNumber    = 50601
+	CastValueTypeNumber  = 50602
+	SSZLimitNumber       = 50010
+)
+
+// NewPlugin builds a plugin for files the way protoc would hand them over,
+// generating every file that is not descriptor.proto.
+func NewPlugin(t testing.TB, parameter string, files ...*descriptorpb.FileDescriptorProto) *protogen.Plugin {
+	t.Helper()
+	gen, err := protogen.Options{}.New(Request(parameter, files...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+// Request returns a request to generate files, which may import
+// descriptor.proto and cast/options.proto.
+func Request(parameter string, files ...*descriptorpb.FileDescriptorProto) *pluginpb.CodeGeneratorRequest {
+	req := &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String(parameter),
+		ProtoFile: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+			protodesc.ToFileDescriptorProto(castpb.File_cast_options_proto),
+		},
+	}
+	for _, f := range files {
+		req.ProtoFile = append(req.ProtoFile, f)
+		req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+	}
+	return req
+}
+
+// File mirrors the option declarations of test.proto, along with a
+// cast_type declared the way protos did before cast/options.proto.
+func File(messages ...*descriptorpb.DescriptorProto) *descriptorpb.FileDescriptorProto {
+	extension := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		fd := Field(name, number, typ)
+		fd.Extendee = proto.String(".google.protobuf.FieldOptions")
+		return fd
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test.proto"),
+		Package:    proto.String("v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/descriptor.proto", "cast/options.proto"},
+		Options: &descriptorpb.FileOptions{
+			GoPackage: proto.String("github.com/prysmaticlabs/protoc-gen-go-cast/test"),
+		},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			extension("ssz_size", SSZSizeNumber, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			extension("ssz_max", SSZMaxNumber, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			extension("spec_name", SpecNameNumber, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			extension("cast_type", LegacyCastTypeNumber, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			extension("ssz_limit", SSZLimitNumber, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		},
+		MessageType: messages,
+	}
+}
+
+// Message returns a message with fields.
+func Message(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{
+		Name:  proto.String(name),
+		Field: fields,
+	}
+}
+
+// WithNested adds nested messages to message.
+func WithNested(message *descriptorpb.DescriptorProto, nested ...*descriptorpb.DescriptorProto) *descriptorpb.DescriptorProto {
+	message.NestedType = append(message.NestedType, nested...)
+	return message
+}
+
+// AddMapField adds a map field to a top level message of File along with
+// its generated entry message.
+func AddMapField(message *descriptorpb.DescriptorProto, name string, number int32, keyType, valueType descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	entryName := strings.ReplaceAll(strings.Title(strings.ReplaceAll(name, "_", " ")), " ", "") + "Entry"
+	entry := Message(entryName, Field("key", 1, keyType), Field("value", 2, valueType))
+	entry.Options = &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)}
+	message.NestedType = append(message.NestedType, entry)
+
+	fd := Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fd.TypeName = proto.String(".v1." + message.GetName() + "." + entryName)
+	message.Field = append(message.Field, fd)
+	return fd
+}
+
+// Field returns a singular field of the given type.
+func Field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   typ.Enum(),
+	}
+}
+
+// CastField sets the cast_type option of fd.
+func CastField(fd *descriptorpb.FieldDescriptorProto, castType string) *descriptorpb.FieldDescriptorProto {
+	return StringOption(fd, CastTypeNumber, castType)
+}
+
+// StringOption sets a custom option as an unknown field, which is how options
+// arrive in the request when their extensions are not linked into the plugin.
+func StringOption(fd *descriptorpb.FieldDescriptorProto, number protowire.Number, value string) *descriptorpb.FieldDescriptorProto {
+	m := fieldOptionsOf(fd).ProtoReflect()
+	b := protowire.AppendTag(m.GetUnknown(), number, protowire.BytesType)
+	m.SetUnknown(protowire.AppendString(b, value))
+	return fd
+}
+
+// Uint64Option sets a varint custom option as an unknown field.
+func Uint64Option(fd *descriptorpb.FieldDescriptorProto, number protowire.Number, value uint64) *descriptorpb.FieldDescriptorProto {
+	m := fieldOptionsOf(fd).ProtoReflect()
+	b := protowire.AppendTag(m.GetUnknown(), number, protowire.VarintType)
+	m.SetUnknown(protowire.AppendVarint(b, value))
+	return fd
+}
+
+func fieldOptionsOf(fd *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldOptions {
+	if fd.Options == nil {
+		fd.Options = &descriptorpb.FieldOptions{}
+	}
+	return fd.Options
+}
+
+// Corpus returns a request for files messages of fields each, shaped like a
+// large beacon chain proto set: one field in four is cast, one in four
+// carries struct tags, and the rest have no custom options.
+func Corpus(files, messages, fields int) *pluginpb.CodeGeneratorRequest {
+	corpus := []*descriptorpb.FileDescriptorProto{File()}
+	for i := 0; i < files; i++ {
+		file := &descriptorpb.FileDescriptorProto{
+			Name:       proto.String(fmt.Sprintf("corpus/file%d.proto", i)),
+			Package:    proto.String(fmt.Sprintf("corpus.file%d", i)),
+			Syntax:     proto.String("proto3"),
+			Dependency: []string{"test.proto", "cast/options.proto"},
+			Options: &descriptorpb.FileOptions{
+				GoPackage: proto.String(fmt.Sprintf("github.com/prysmaticlabs/protoc-gen-go-cast/corpus/file%d", i)),
+			},
+		}
+		for j := 0; j < messages; j++ {
+			message := Message(fmt.Sprintf("Message%d", j))
+			for k := 0; k < fields; k++ {
+				name, number := fmt.Sprintf("field_%d", k), int32(k+1)
+				var fd *descriptorpb.FieldDescriptorProto
+				switch k % 4 {
+				case 0:
+					fd = CastField(Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_UINT64), "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Epoch")
+				case 1:
+					fd = StringOption(StringOption(Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_BYTES), SSZSizeNumber, "32"), SpecNameNumber, name)
+				default:
+					fd = Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_UINT64)
+				}
+				message.Field = append(message.Field, fd)
+			}
+			file.MessageType = append(file.MessageType, message)
+		}
+		corpus = append(corpus, file)
+	}
+	return Request("", corpus...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// lintOutput is where lint warnings are written. Unlike the other output of
+// the plugin, they are not silenced by silent=true.
+var lintOutput io.Writer = os.Stderr
+
+// lintFiles checks the annotations of the files to generate.
+func lintFiles(c *cast.Caster, gen *protogen.Plugin, strict bool) error {
+	var diags []cast.Diagnostic
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		fileDiags, err := c.Lint(f)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, fileDiags...)
+	}
+	return reportDiagnostics(diags, strict)
+}
+
+// reportDiagnostics returns an error listing the errors among diags, and
+// the warnings too if strict. Other warnings are written to lintOutput.
+func reportDiagnostics(diags []cast.Diagnostic, strict bool) error {
+	var failures []string
+	for _, d := range diags {
+		if d.Severity == cast.Error || strict {
+			failures = append(failures, d.String())
+			continue
+		}
+		fmt.Fprintln(lintOutput, d)
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
@@ -7,52 +7,114 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
 	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
 	"google.golang.org/protobuf/compiler/protogen"
 )
 
 func main() {
 	if len(os.Args) == 2 && os.Args[1] == "--version" {
-		fmt.Fprintf(os.Stderr, "%v\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stdout, "%v %v\n", filepath.Base(os.Args[0]), cast.Version())
 		os.Exit(0)
 	}
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s: %v\n", filepath.Base(os.Args[0]), os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	opts, generate := newPlugin()
+	opts.Run(generate)
+}
+
+// subcommands are run instead of the plugin when named by the first
+// argument.
+var subcommands = map[string]func(args []string) error{
+	"replay":  replay,
+	"rewrite": rewrite,
+}
+
+// planFunc plans the rewrite of the .pb.go file of f.
+type planFunc func(c *cast.Caster, gen *protogen.Plugin, f *protogen.File) (*cast.Plan, error)
 
+// newPlugin returns the protogen options of the plugin, which parse its
+// parameters, and the function that generates the files of a request.
+func newPlugin() (protogen.Options, func(gen *protogen.Plugin) error) {
+	return newCastPlugin(generatePlan)
+}
+
+// generatePlan generates the .pb.go file of f the way protoc-gen-go does and
+// plans its rewrite.
+func generatePlan(c *cast.Caster, gen *protogen.Plugin, f *protogen.File) (*cast.Plan, error) {
+	return c.BuildPlan(f, gengo.GenerateFile(gen, f))
+}
+
+// newCastPlugin is newPlugin with the .pb.go files to cast planned by planFile.
+func newCastPlugin(planFile planFunc) (protogen.Options, func(gen *protogen.Plugin) error) {
 	var (
-		flags        flag.FlagSet
-		plugins      = flags.String("plugins", "", "list of plugins to enable (supported values: grpc)")
-		importPrefix = flags.String("import_prefix", "", "prefix to prepend to import paths")
-		silent       = flags.Bool("silent", false, "silence the output")
+		flags       flag.FlagSet
+		importRules = newImportRules()
+		plugins     = flags.String("plugins", "", "plugins to enable, separated by + as in plugins=grpc+foo (supported values: "+strings.Join(generatorNames(), ", ")+")")
+		rulesFile   = flags.String("import_rules", "", "file of import rules, one NAME=VALUE per line")
+		silent      = flags.Bool("silent", false, "silence the output")
+		setters     = flags.Bool("setters", false, "generate typed setters for cast fields")
+		legacyNames = flags.Bool("legacy_option_names", false, "match cast options declared outside of cast/options.proto by their short name")
+		dumpRequest = flags.String("dump_request", "", "file to write the CodeGeneratorRequest to, for the replay subcommand")
+		jobs        = flags.Int("jobs", runtime.GOMAXPROCS(0), "number of files to cast at a time")
+		lint        = flags.Bool("lint", false, "check cast and tag annotations, failing on errors and reporting warnings")
+		lintStrict  = flags.Bool("lint_strict", false, "check cast and tag annotations, failing on errors and on warnings, including those of check_types")
+		castPlan    = flags.Bool("cast_plan", false, "write a .cast_plan.json file next to each .pb.go file, listing the fields it casts and tags")
+		checkCasts  = flags.Bool("check_types", false, "check that cast types exist and match the Go types of their fields, loading their packages with the go command")
 	)
-	importRewriteFunc := func(importPath protogen.GoImportPath) protogen.GoImportPath {
-		switch importPath {
-		case "context", "fmt", "math":
-			return importPath
-		}
-		if *importPrefix != "" {
-			return protogen.GoImportPath(*importPrefix) + importPath
-		}
-		return importPath
+	flags.Var(importRuleFlag{importRules, "import_prefix"}, "import_prefix", "prefix to prepend to import paths")
+	flags.Var(importRuleFlag{importRules, "import_exclude"}, "import_exclude", "import path to keep as is, along with the paths below it")
+	flags.Var(importRuleFlag{importRules, "import_map"}, "import_map", "FROM=TO replacing the leading FROM of import paths with TO")
+	flags.Var(importRuleFlag{importRules, "import_regexp"}, "import_regexp", "REGEXP=REPLACEMENT rewriting the import paths REGEXP matches")
+	for _, name := range generatorNames() {
+		generators[name].Params(&flags)
+	}
+	opts := protogen.Options{
+		ParamFunc: func(name, value string) error {
+			// A plugin listed after a comma, as in plugins=grpc,foo, is
+			// split off by protoc as a parameter of its own.
+			if _, ok := generators[name]; ok && value == "" && flags.Lookup(name) == nil {
+				return fmt.Errorf("parameter %q names a plugin: protoc splits parameters on commas, so separate plugins with \"+\"", name)
+			}
+			return flags.Set(name, value)
+		},
+		ImportRewriteFunc: importRules.rewrite,
 	}
-	protogen.Options{
-		ParamFunc:         flags.Set,
-		ImportRewriteFunc: importRewriteFunc,
-	}.Run(func(gen *protogen.Plugin) error {
+	return opts, func(gen *protogen.Plugin) error {
 		if *silent {
 			log.SetOutput(io.Discard)
 		}
-		grpc := false
-		for _, plugin := range strings.Split(*plugins, ",") {
-			log.Println(plugin)
-			switch plugin {
-			case "grpc":
-				grpc = true
-			case "":
-			default:
-				return fmt.Errorf("protoc-gen-go: unknown plugin %q", plugin)
+		if *jobs < 1 {
+			return fmt.Errorf("protoc-gen-go: jobs=%d: want at least 1", *jobs)
+		}
+		if *dumpRequest != "" {
+			if err := writeRequest(*dumpRequest, gen.Request); err != nil {
+				return fmt.Errorf("protoc-gen-go: dump_request: %v", err)
+			}
+		}
+		if *rulesFile != "" {
+			if err := importRules.load(*rulesFile); err != nil {
+				return fmt.Errorf("protoc-gen-go: import_rules: %v", err)
 			}
 		}
+		enabled, err := enabledGenerators(*plugins)
+		if err != nil {
+			return fmt.Errorf("protoc-gen-go: %v", err)
+		}
+		for _, g := range enabled {
+			log.Println(g.Name())
+		}
 		var allExtensions []*protogen.Extension
 		for _, f := range gen.Files {
 			allExtensions = append(allExtensions, f.Extensions...)
@@ -62,17 +124,60 @@ func main() {
 			extensionNames[i] = string(ee.Desc.Name())
 		}
 		log.Printf("Casting for %d extensions: %s\n", len(allExtensions), strings.Join(extensionNames, ", "))
+		c, err := cast.NewCaster(gen, cast.Options{
+			Setters:           *setters,
+			LegacyOptionNames: *legacyNames,
+			ImportRewriteFunc: importRules.rewrite,
+			Jobs:              *jobs,
+		})
+		if err != nil {
+			return fmt.Errorf("protoc-gen-go: %v", err)
+		}
+		if *lint || *lintStrict {
+			if err := lintFiles(c, gen, *lintStrict); err != nil {
+				return err
+			}
+		}
+		if *checkCasts {
+			if err := checkTypes(c, gen, *lintStrict); err != nil {
+				return err
+			}
+		}
+		// Planning and the generators add files to gen and share the decoded
+		// options, so they run one file at a time. Only the rewrite of the
+		// planned files runs concurrently.
+		var plans []*cast.Plan
 		for _, f := range gen.Files {
 			if !f.Generate {
 				continue
 			}
-			gennedFile := gengo.GenerateFile(gen, f)
-			if grpc {
-				GenerateFileContent(gen, f, gennedFile)
+			plan, err := planFile(c, gen, f)
+			if err != nil {
+				return err
+			}
+			for _, g := range enabled {
+				if err := g.Generate(gen, f, plan); err != nil {
+					return fmt.Errorf("%s: %s: %v", f.Desc.Path(), g.Name(), err)
+				}
+			}
+			plans = append(plans, plan)
+		}
+		if err := c.Apply(plans...); err != nil {
+			return err
+		}
+		if *castPlan {
+			for _, plan := range plans {
+				report, err := c.Report(plan)
+				if err != nil {
+					return err
+				}
+				g := gen.NewGeneratedFile(plan.File().GeneratedFilenamePrefix+".cast_plan.json", "")
+				if _, err := g.Write(report); err != nil {
+					return err
+				}
 			}
-			GenerateCastedFile(gen, gennedFile, f, allExtensions)
 		}
 		gen.SupportedFeatures = gengo.SupportedFeatures
 		return nil
-	})
+	}
 }
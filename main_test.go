@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
+	"github.com/prysmaticlabs/protoc-gen-go-cast/internal/casttest"
+	gengo "google.golang.org/protobuf/cmd/protoc-gen-go/internal_gengo"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func Test_importRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		paths map[string]string
+	}{
+		{
+			name: "no rules",
+			paths: map[string]string{
+				"github.com/prysmaticlabs/go-bitfield": "github.com/prysmaticlabs/go-bitfield",
+			},
+		},
+		{
+			name:  "prefix",
+			rules: []string{"import_prefix=vendor/"},
+			paths: map[string]string{
+				"github.com/prysmaticlabs/go-bitfield": "vendor/github.com/prysmaticlabs/go-bitfield",
+				"context":                              "context",
+				"math":                                 "math",
+			},
+		},
+		{
+			name:  "exclude",
+			rules: []string{"import_prefix=vendor/", "import_exclude=google.golang.org/protobuf"},
+			paths: map[string]string{
+				"google.golang.org/protobuf":                      "google.golang.org/protobuf",
+				"google.golang.org/protobuf/reflect/protoreflect": "google.golang.org/protobuf/reflect/protoreflect",
+				"google.golang.org/protobufx":                     "vendor/google.golang.org/protobufx",
+			},
+		},
+		{
+			name:  "map",
+			rules: []string{"import_prefix=vendor/", "import_map=github.com/prysmaticlabs=example.com/prysm"},
+			paths: map[string]string{
+				"github.com/prysmaticlabs/go-bitfield": "example.com/prysm/go-bitfield",
+				"github.com/prysmaticlabs":             "example.com/prysm",
+				"github.com/prysmaticlabsx/foo":        "vendor/github.com/prysmaticlabsx/foo",
+			},
+		},
+		{
+			name:  "regexp",
+			rules: []string{"import_regexp=^github\\.com/([^/]+)/(.*)$=third_party/$1/$2", "import_map=github.com/prysmaticlabs=unused"},
+			paths: map[string]string{
+				"github.com/prysmaticlabs/go-bitfield": "third_party/prysmaticlabs/go-bitfield",
+				"google.golang.org/grpc":               "google.golang.org/grpc",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := newImportRules()
+			for _, rule := range tt.rules {
+				i := strings.Index(rule, "=")
+				if err := rules.set(rule[:i], rule[i+1:]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for path, want := range tt.paths {
+				if got := rules.rewrite(protogen.GoImportPath(path)); string(got) != want {
+					t.Errorf("rewrite(%q) = %q, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_importRules_load(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "import_rules.txt")
+	content := "# Keep the protobuf runtime out of the vendor tree.\n\nimport_prefix=vendor/\nimport_exclude=google.golang.org/protobuf\n"
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules := newImportRules()
+	if err := rules.load(filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := rules.rewrite("google.golang.org/protobuf/proto"); got != "google.golang.org/protobuf/proto" {
+		t.Errorf("rewrite() = %q, want it unchanged", got)
+	}
+	if got := rules.rewrite("github.com/prysmaticlabs/go-bitfield"); got != "vendor/github.com/prysmaticlabs/go-bitfield" {
+		t.Errorf("rewrite() = %q, want it prefixed", got)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte("import_regexp=(=x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newImportRules().load(filename); err == nil || !strings.HasPrefix(err.Error(), filename+":1: import_regexp=(=x: ") {
+		t.Errorf("load() error = %v, want an error at line 1", err)
+	}
+}
+
+func TestGenerate_importRules(t *testing.T) {
+	resp := generate(t, casttest.Request("import_prefix=vendor/", casttest.File(casttest.Message("Imports",
+		casttest.CastField(casttest.Field("plain", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/a/primitives.Bytes"),
+		casttest.CastField(casttest.Field("aliased", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield;bf.Bitlist"),
+	))))
+	content := resp.File[len(resp.File)-1].GetContent()
+	for _, want := range []string{
+		"\tprimitives \"vendor/github.com/a/primitives\"\n",
+		"\tbf \"vendor/github.com/prysmaticlabs/go-bitfield\"\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+}
+
+func Test_enabledGenerators(t *testing.T) {
+	tests := []struct {
+		plugins string
+		want    []string
+		wantErr string
+	}{
+		{plugins: "", want: nil},
+		{plugins: "grpc", want: []string{"grpc"}},
+		{plugins: "grpc+grpc", want: []string{"grpc"}},
+		{plugins: "grpc+foo", wantErr: `unknown plugin "foo" (supported: grpc)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plugins, func(t *testing.T) {
+			enabled, err := enabledGenerators(tt.plugins)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("enabledGenerators() error = %v, want %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, g := range enabled {
+				got = append(got, g.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("enabledGenerators() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// markerGenerator adds a constant naming the source of the .pb.go file.
+type markerGenerator struct{}
+
+func (markerGenerator) Name() string { return "marker" }
+
+func (markerGenerator) Params(*flag.FlagSet) {}
+
+func (markerGenerator) Generate(gen *protogen.Plugin, file *protogen.File, plan *cast.Plan) error {
+	plan.GeneratedFile().P("const castSource = ", strconv.Quote(plan.File().Desc.Path()))
+	return nil
+}
+
+func TestGenerate_generators(t *testing.T) {
+	registerGenerator(markerGenerator{})
+	defer delete(generators, "marker")
+	resp := generate(t, casttest.Request("plugins=marker", casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"),
+	))))
+	content := resp.File[len(resp.File)-1].GetContent()
+	for _, want := range []string{
+		`const castSource = "test.proto"`,
+		"func (x *Attestation) GetAggregationBits() bitfield.Bitlist {",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated file does not contain %q", want)
+		}
+	}
+
+	opts, _ := newPlugin()
+	_, err := opts.New(casttest.Request("plugins=grpc,marker", casttest.File(casttest.Message("Attestation"))))
+	if want := `parameter "marker" names a plugin: protoc splits parameters on commas, so separate plugins with "+"`; err == nil || err.Error() != want {
+		t.Errorf("New() error = %v, want %v", err, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	dumped := filepath.Join(dir, "request.bin")
+	req := casttest.Request("silent=true,dump_request="+dumped, casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "bitfield.Bitlist"),
+	)))
+	opts, generate := newPlugin()
+	gen, err := opts.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(gen); err != nil {
+		t.Fatal(err)
+	}
+	want := gen.Response().File[0].GetContent()
+
+	b, err := ioutil.ReadFile(dumped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decode options as unknown fields, like the request the plugin was given.
+	got := &pluginpb.CodeGeneratorRequest{}
+	if err := (proto.UnmarshalOptions{Resolver: new(protoregistry.Types)}).Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, req) {
+		t.Error("dumped request differs from the request")
+	}
+
+	out := filepath.Join(dir, "out")
+	if err := replay([]string{"-out", out, dumped}); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := ioutil.ReadFile(filepath.Join(out, "github.com/prysmaticlabs/protoc-gen-go-cast/test/test.pb.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(replayed) != want {
+		t.Error("replayed file differs from the generated one")
+	}
+	if err := replay([]string{"-out", out, "-param", "plugins=foo", dumped}); err == nil || !strings.Contains(err.Error(), `unknown plugin "foo"`) {
+		t.Errorf("replay() error = %v, want an unknown plugin error", err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	req := casttest.Request("", casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield.Bitlist"),
+		// protoimpl is already imported by the file being rewritten.
+		casttest.CastField(casttest.Field("clash", 3, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/x/protoimpl.T"),
+		casttest.StringOption(casttest.Field("signature", 2, descriptorpb.FieldDescriptorProto_TYPE_BYTES), casttest.SSZSizeNumber, "96"),
+	)))
+	want := generate(t, proto.Clone(req).(*pluginpb.CodeGeneratorRequest)).File[0].GetContent()
+
+	// Generate the file the way protoc-gen-go does, to rewrite it.
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gengo.GenerateFile(gen, gen.FilesByPath["test.proto"])
+	plain := gen.Response().File[0].GetContent()
+
+	dir := t.TempDir()
+	descriptorSet := filepath.Join(dir, "test.pb")
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: req.ProtoFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(descriptorSet, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "test.pb.go")
+	if err := ioutil.WriteFile(filename, []byte(plain), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-descriptor_set", descriptorSet, "-param", "silent=true,cast_plan=true", filename}
+	if err := rewrite(args); err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rewritten) != want {
+		t.Errorf("rewritten file differs from the generated one:\n%s", rewritten)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.cast_plan.json")); err != nil {
+		t.Errorf("cast plan not written next to the rewritten file: %v", err)
+	}
+	if err := rewrite(args); err == nil || !strings.Contains(err.Error(), filename+": already cast") {
+		t.Errorf("rewrite() error = %v, want an already cast error", err)
+	}
+}
+
+func Test_withoutParam(t *testing.T) {
+	if got, want := withoutParam("plugins=grpc,dump_request=req.bin,silent=true,dump_request", "dump_request"), "plugins=grpc,silent=true"; got != want {
+		t.Errorf("withoutParam() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerate_jobs(t *testing.T) {
+	want := generate(t, casttest.Corpus(6, 3, 4))
+	if len(want.File) != 7 {
+		t.Fatalf("generated %d files, want 7", len(want.File))
+	}
+	for _, param := range []string{"jobs=1", "jobs=4", "jobs=64"} {
+		req := casttest.Corpus(6, 3, 4)
+		req.Parameter = proto.String(param)
+		if got := generate(t, req); !proto.Equal(got, want) {
+			t.Errorf("%s: response differs from the default", param)
+		}
+	}
+
+	opts, generate := newPlugin()
+	gen, err := opts.New(casttest.Request("jobs=0", casttest.File()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(gen); err == nil || !strings.Contains(err.Error(), "jobs=0") {
+		t.Errorf("jobs=0: got error %v, want one naming the parameter", err)
+	}
+}
+
+func TestGenerate_lint(t *testing.T) {
+	var out bytes.Buffer
+	lintOutput = &out
+	defer func() { lintOutput = os.Stderr }()
+	file := casttest.File(casttest.Message("Checkpoint",
+		casttest.StringOption(casttest.Field("epoch", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64), casttest.CastKeyTypeNumber, "github.com/a/primitives.Epoch"),
+	))
+	warning := "test.proto: warning: field v1.Checkpoint.epoch: (cast_key_type) is ignored on fields that are not maps"
+
+	generate(t, casttest.Request("lint=true", file))
+	if got := out.String(); got != warning+"\n" {
+		t.Errorf("lint=true: got output %q, want the warning", got)
+	}
+
+	out.Reset()
+	opts, run := newPlugin()
+	gen, err := opts.New(casttest.Request("silent=true,lint_strict=true", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run(gen); err == nil || err.Error() != warning {
+		t.Errorf("lint_strict=true: got error %v, want the warning", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("lint_strict=true: got output %q, want none", out.String())
+	}
+}
+
+func TestGenerate_checkTypes(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("loading packages needs the go command")
+	}
+	file := func(bitsType, epochType string) *descriptorpb.FileDescriptorProto {
+		return casttest.File(casttest.Message("Attestation",
+			casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), bitsType),
+			casttest.CastField(casttest.Field("epoch", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64), epochType),
+		))
+	}
+	generate(t, casttest.Request("check_types=true", file("github.com/prysmaticlabs/go-bitfield;bitfield.Bitlist", "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Epoch")))
+
+	opts, run := newPlugin()
+	gen, err := opts.New(casttest.Request("silent=true,check_types=true", file("github.com/prysmaticlabs/go-bitfield;bitfield.Bitlst", "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Bytes")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `test.proto: error: field v1.Attestation.aggregation_bits: (cast_type) "github.com/prysmaticlabs/go-bitfield;bitfield.Bitlst": package github.com/prysmaticlabs/go-bitfield declares no Bitlst
+test.proto: error: field v1.Attestation.epoch: (cast_type) "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Bytes": underlying type is []byte, want uint64`
+	if err := run(gen); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
+
+// roundTripTest marshals and unmarshals the messages of the file
+// TestGenerate_roundTrip generates, from within its package.
+const roundTripTest = `package test
+
+import (
+	"testing"
+
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRoundTrip(t *testing.T) {
+	m := &Validator{
+		Status:      primitives.Exited,
+		History:     []primitives.ValidatorStatus{primitives.Active, primitives.Exited},
+		BitsByIndex: map[primitives.ValidatorIndex]bitfield.Bitlist{3: {1, 2}},
+		Epochs:      map[string]primitives.Epoch{"a": 7},
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &Validator{}
+	if err := proto.Unmarshal(b, got); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, m) {
+		t.Errorf("Unmarshal() = %v, want %v", got, m)
+	}
+	if got.GetStatus() != primitives.Exited || got.GetHistory()[1] != primitives.Exited || got.GetBitsByIndex()[3][1] != 2 || got.GetEpochs()["a"] != 7 {
+		t.Errorf("getters of %v return the wrong values", got)
+	}
+	status := got.ProtoReflect().Descriptor().Fields().ByName("status")
+	if v := got.ProtoReflect().Get(status).Enum(); v != 2 {
+		t.Errorf("status is %v through protoreflect, want 2", v)
+	}
+}
+`
+
+func TestGenerate_roundTrip(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("building the generated file needs the go command")
+	}
+	enumField := func(name string, number int32) *descriptorpb.FieldDescriptorProto {
+		fd := casttest.CastField(casttest.Field(name, number, descriptorpb.FieldDescriptorProto_TYPE_ENUM), "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.ValidatorStatus")
+		fd.TypeName = proto.String(".v1.ValidatorStatus")
+		return fd
+	}
+	history := enumField("history", 2)
+	history.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	message := casttest.Message("Validator", enumField("status", 1), history)
+	casttest.StringOption(casttest.StringOption(
+		casttest.AddMapField(message, "bits_by_index", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+		casttest.CastKeyTypeNumber, "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.ValidatorIndex"),
+		casttest.CastValueTypeNumber, "github.com/prysmaticlabs/go-bitfield.Bitlist")
+	casttest.StringOption(
+		casttest.AddMapField(message, "epochs", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		casttest.CastValueTypeNumber, "github.com/prysmaticlabs/protoc-gen-go-cast/test/primitives.Epoch")
+	file := casttest.File(message)
+	file.EnumType = []*descriptorpb.EnumDescriptorProto{{
+		Name: proto.String("ValidatorStatus"),
+		Value: []*descriptorpb.EnumValueDescriptorProto{
+			{Name: proto.String("UNKNOWN_STATUS"), Number: proto.Int32(0)},
+			{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+			{Name: proto.String("EXITED"), Number: proto.Int32(2)},
+		},
+	}}
+	resp := generate(t, casttest.Request("", file))
+
+	// The generated file is built in a module of its own, which takes this
+	// module from the working tree and its dependencies from the module
+	// cache, as listed in the go.sum of this module.
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	goSum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := "module roundtrip\n\n" +
+		"require github.com/prysmaticlabs/protoc-gen-go-cast v0.0.0\n\n" +
+		"replace github.com/prysmaticlabs/protoc-gen-go-cast => " + root + "\n"
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":            goMod,
+		"go.sum":            string(goSum),
+		"test.pb.go":        resp.File[0].GetContent(),
+		"roundtrip_test.go": roundTripTest,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "test", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPROXY=off", "GOFLAGS=-mod=mod")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go test of the generated file failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerate_castPlan(t *testing.T) {
+	file := casttest.File(casttest.Message("Attestation",
+		casttest.CastField(casttest.Field("aggregation_bits", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES), "github.com/prysmaticlabs/go-bitfield;bitfield.Bitlist"),
+	))
+	if resp := generate(t, casttest.Request("", file)); len(resp.File) != 1 {
+		t.Errorf("generated %d files without cast_plan, want 1", len(resp.File))
+	}
+
+	resp := generate(t, casttest.Request("cast_plan=true", file))
+	var names []string
+	for _, f := range resp.File {
+		names = append(names, f.GetName())
+	}
+	want := []string{
+		"github.com/prysmaticlabs/protoc-gen-go-cast/test/test.pb.go",
+		"github.com/prysmaticlabs/protoc-gen-go-cast/test/test.cast_plan.json",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("generated files %v, want %v", names, want)
+	}
+	if content := resp.File[1].GetContent(); !strings.Contains(content, `"cast_type": "github.com/prysmaticlabs/go-bitfield.Bitlist"`) {
+		t.Errorf("cast plan does not list the cast type:\n%s", content)
+	}
+}
+
+// BenchmarkGenerate measures a whole run of the plugin over a large corpus,
+// casting one file at a time and as many as the default number of jobs.
+func BenchmarkGenerate(b *testing.B) {
+	for _, param := range []string{"jobs=1", ""} {
+		name := param
+		if name == "" {
+			name = "default"
+		}
+		b.Run(name, func(b *testing.B) {
+			req := casttest.Corpus(5, 40, 20)
+			req.Parameter = proto.String("silent=true," + param)
+			for i := 0; i < b.N; i++ {
+				opts, generate := newPlugin()
+				gen, err := opts.New(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := generate(gen); err != nil {
+					b.Fatal(err)
+				}
+				if resp := gen.Response(); resp.Error != nil {
+					b.Fatal(resp.GetError())
+				}
+			}
+		})
+	}
+}
+
+// generate runs the plugin on req and fails t if it reports an error.
+func generate(t *testing.T, req *pluginpb.CodeGeneratorRequest) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	req.Parameter = proto.String("silent=true," + req.GetParameter())
+	opts, generate := newPlugin()
+	gen, err := opts.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(gen); err != nil {
+		t.Fatal(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	return resp
+}
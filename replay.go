@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// writeRequest saves req to filename, as dump_request does.
+func writeRequest(filename string, req *pluginpb.CodeGeneratorRequest) error {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// replay runs the plugin on a request saved with dump_request and writes the
+// generated files under a directory, the way protoc would with --go-cast_out.
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s replay [flags] request.bin\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	out := flags.String("out", ".", "directory to write the generated files to")
+	param := flags.String("param", "", "plugin parameter to use instead of the one in the request")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("want exactly one request file")
+	}
+
+	b, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	req := &pluginpb.CodeGeneratorRequest{}
+	if err := proto.Unmarshal(b, req); err != nil {
+		return fmt.Errorf("%s: %v", flags.Arg(0), err)
+	}
+	if *param != "" {
+		req.Parameter = param
+	}
+	// Replaying must not overwrite the request it reads.
+	req.Parameter = proto.String(withoutParam(req.GetParameter(), "dump_request"))
+
+	opts, generate := newPlugin()
+	gen, err := opts.New(req)
+	if err != nil {
+		return err
+	}
+	if err := generate(gen); err != nil {
+		gen.Error(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		return errors.New(resp.GetError())
+	}
+	for _, file := range resp.File {
+		if file.GetInsertionPoint() != "" {
+			return fmt.Errorf("%s: insertion points are not supported", file.GetName())
+		}
+		filename := filepath.Join(*out, filepath.FromSlash(file.GetName()))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, []byte(file.GetContent()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutParam removes every NAME or NAME=VALUE entry with the given name
+// from a comma-separated plugin parameter.
+func withoutParam(parameter, name string) string {
+	var kept []string
+	for _, param := range strings.Split(parameter, ",") {
+		if param == name || strings.HasPrefix(param, name+"=") {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return strings.Join(kept, ",")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// rewrite casts .pb.go files protoc-gen-go already generated, in place. The
+// .proto files they were generated from are read from a FileDescriptorSet,
+// as protoc writes with --include_imports and -o.
+func rewrite(args []string) error {
+	flags := flag.NewFlagSet("rewrite", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s rewrite -descriptor_set=FILE [flags] file.pb.go...\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	descriptorSet := flags.String("descriptor_set", "", "FileDescriptorSet of the .proto files and their imports")
+	param := flags.String("param", "", "plugin parameter, as given to --go-cast_opt")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *descriptorSet == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("want a descriptor set and at least one .pb.go file")
+	}
+
+	b, err := ioutil.ReadFile(*descriptorSet)
+	if err != nil {
+		return err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return fmt.Errorf("%s: %v", *descriptorSet, err)
+	}
+
+	// filenames and contents are keyed by the path of the .proto file each
+	// .pb.go file was generated from.
+	filenames := make(map[string]string)
+	contents := make(map[string][]byte)
+	for _, filename := range flags.Args() {
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		source := sourcePath(content)
+		if source == "" {
+			return fmt.Errorf("%s: no source .proto file in the header", filename)
+		}
+		if other, ok := filenames[source]; ok {
+			return fmt.Errorf("%s and %s are both generated from %s", other, filename, source)
+		}
+		filenames[source] = filename
+		contents[source] = content
+	}
+	req := &pluginpb.CodeGeneratorRequest{
+		Parameter: proto.String(withoutParam(*param, "dump_request")),
+		ProtoFile: set.File,
+	}
+	inSet := make(map[string]bool)
+	for _, f := range set.File {
+		inSet[f.GetName()] = true
+		if _, ok := filenames[f.GetName()]; ok {
+			req.FileToGenerate = append(req.FileToGenerate, f.GetName())
+		}
+	}
+	for source, filename := range filenames {
+		if !inSet[source] {
+			return fmt.Errorf("%s: %s is not in %s", filename, source, *descriptorSet)
+		}
+	}
+
+	opts, generate := newCastPlugin(func(c *cast.Caster, gen *protogen.Plugin, f *protogen.File) (*cast.Plan, error) {
+		plan, err := c.LoadPlan(f, contents[f.Desc.Path()])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filenames[f.Desc.Path()], err)
+		}
+		return plan, nil
+	})
+	gen, err := opts.New(req)
+	if err != nil {
+		return err
+	}
+	if err := generate(gen); err != nil {
+		gen.Error(err)
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		return errors.New(resp.GetError())
+	}
+
+	// Map the names protoc would have written the files under back to the
+	// files given, and check them all before writing any.
+	outputs := make(map[string]string)
+	for source, filename := range filenames {
+		prefix := gen.FilesByPath[source].GeneratedFilenamePrefix
+		outputs[prefix+".pb.go"] = filename
+		outputs[prefix+".pb.go.meta"] = filename + ".meta"
+		outputs[prefix+".cast_plan.json"] = strings.TrimSuffix(filename, ".pb.go") + ".cast_plan.json"
+	}
+	for _, file := range resp.File {
+		if _, ok := outputs[file.GetName()]; !ok || file.GetInsertionPoint() != "" {
+			return fmt.Errorf("%s: only .pb.go files and their cast plans can be written", file.GetName())
+		}
+	}
+	for _, file := range resp.File {
+		if err := ioutil.WriteFile(outputs[file.GetName()], []byte(file.GetContent()), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourcePath returns the path of the .proto file named in the header of a
+// .pb.go file, or an empty string if the header names none.
+func sourcePath(content []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if strings.HasPrefix(line, "// source: ") {
+			return strings.TrimPrefix(line, "// source: ")
+		}
+		if strings.HasPrefix(line, "// ") && strings.HasSuffix(line, " is a deprecated file.") {
+			return strings.TrimSuffix(strings.TrimPrefix(line, "// "), " is a deprecated file.")
+		}
+	}
+	return ""
+}
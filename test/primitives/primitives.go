@@ -0,0 +1,47 @@
+// Package primitives declares named Go types that test.proto casts fields to.
+package primitives
+
+// ValidatorIndex is the index of a validator in the beacon state.
+type ValidatorIndex uint64
+
+// Epoch is a beacon chain epoch number.
+type Epoch uint64
+
+// The types below cover the Go type of every protobuf scalar kind.
+
+// Bool is cast from bool.
+type Bool bool
+
+// Int32 is cast from int32, sint32 and sfixed32.
+type Int32 int32
+
+// Int64 is cast from int64, sint64 and sfixed64.
+type Int64 int64
+
+// Uint32 is cast from uint32 and fixed32.
+type Uint32 uint32
+
+// Uint64 is cast from uint64 and fixed64.
+type Uint64 uint64
+
+// Float32 is cast from float.
+type Float32 float32
+
+// Float64 is cast from double.
+type Float64 float64
+
+// String is cast from string.
+type String string
+
+// Bytes is cast from bytes.
+type Bytes []byte
+
+// ValidatorStatus mirrors the ValidatorStatus enum of test.proto.
+type ValidatorStatus int32
+
+// Values of ValidatorStatus.
+const (
+	UnknownStatus ValidatorStatus = iota
+	Active
+	Exited
+)
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"runtime"
+
+	"github.com/prysmaticlabs/protoc-gen-go-cast/cast"
+	"golang.org/x/tools/go/packages"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+// checkTypes checks the cast types of the files to generate against the
+// packages that declare them.
+func checkTypes(c *cast.Caster, gen *protogen.Plugin, strict bool) error {
+	var paths []string
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		filePaths, err := c.CastImportPaths(f)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filePaths...)
+	}
+	pkgs, err := loadPackages(paths)
+	if err != nil {
+		return err
+	}
+	var diags []cast.Diagnostic
+	for _, f := range gen.Files {
+		if !f.Generate {
+			continue
+		}
+		fileDiags, err := c.CheckTypes(f, pkgs)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, fileDiags...)
+	}
+	return reportDiagnostics(diags, strict)
+}
+
+// loadPackages type-checks the packages with the given import paths, as
+// found from the directory the plugin runs in, and returns those that load
+// without errors. The go command is kept off the network, so only packages
+// of the local module cache, vendor tree or GOPATH are loaded.
+//
+// go/packages only finds the packages. They are type-checked from source, as
+// the export data the go command writes changes between its releases.
+func loadPackages(paths []string) (map[string]*types.Package, error) {
+	pkgs := make(map[string]*types.Package)
+	if len(paths) == 0 {
+		return pkgs, nil
+	}
+	loaded, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps,
+		Env:  append(os.Environ(), "GOPROXY=off"),
+	}, paths...)
+	if err != nil {
+		return nil, err
+	}
+	checker := &sourceChecker{
+		fset:    token.NewFileSet(),
+		sizes:   types.SizesFor("gc", runtime.GOARCH),
+		checked: make(map[string]*checkedPackage),
+	}
+	for _, pkg := range loaded {
+		if typesPkg, err := checker.check(pkg); err == nil {
+			pkgs[pkg.PkgPath] = typesPkg
+		}
+	}
+	return pkgs, nil
+}
+
+// sourceChecker type-checks packages and their dependencies from source,
+// each package once. Function bodies are skipped, as only declarations are
+// looked up.
+type sourceChecker struct {
+	fset    *token.FileSet
+	sizes   types.Sizes
+	checked map[string]*checkedPackage
+}
+
+type checkedPackage struct {
+	pkg *types.Package
+	err error
+}
+
+func (c *sourceChecker) check(pkg *packages.Package) (*types.Package, error) {
+	if pkg.PkgPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if checked, ok := c.checked[pkg.ID]; ok {
+		return checked.pkg, checked.err
+	}
+	typesPkg, err := c.checkFiles(pkg)
+	c.checked[pkg.ID] = &checkedPackage{pkg: typesPkg, err: err}
+	return typesPkg, err
+}
+
+func (c *sourceChecker) checkFiles(pkg *packages.Package) (*types.Package, error) {
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+	var files []*ast.File
+	for _, filename := range pkg.GoFiles {
+		f, err := parser.ParseFile(c.fset, filename, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := &types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			imported, ok := pkg.Imports[path]
+			if !ok {
+				return nil, fmt.Errorf("%s does not import %s", pkg.PkgPath, path)
+			}
+			return c.check(imported)
+		}),
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		Sizes:            c.sizes,
+	}
+	return conf.Check(pkg.PkgPath, c.fset, files, nil)
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}